@@ -0,0 +1,96 @@
+package log
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// TransactionIDHeader is the header HTTPMiddleware reads an incoming transaction ID from and
+// echoes it back on
+const TransactionIDHeader = "X-Request-Id"
+
+type ctxKey int
+
+const (
+	loggerCtxKey ctxKey = iota
+	transactionIDCtxKey
+)
+
+// TransactionLogger is a Logger that can derive a child logger tagged with a transaction ID
+type TransactionLogger interface {
+	Logger
+	WithTransactionID(id string) Logger
+}
+
+func (l StdOutLogger) WithTransactionID(id string) Logger {
+	l.TransactionID = id
+	return l
+}
+
+func (l *LeveledLogger) WithTransactionID(id string) Logger {
+	child := *l
+	child.transactionID = id
+	return &child
+}
+
+// WithTransactionID returns a child of l tagged with id. Loggers that do not implement
+// TransactionLogger are returned as is.
+func WithTransactionID(l Logger, id string) Logger {
+	if tl, ok := l.(TransactionLogger); ok && id != "" {
+		return tl.WithTransactionID(id)
+	}
+	return l
+}
+
+// NewTransactionID generates a random 32 character hex transaction ID
+func NewTransactionID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// NewContext returns a copy of ctx carrying l. Use FromContext to get it back out.
+func NewContext(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, loggerCtxKey, l)
+}
+
+// ContextWithTransactionID returns a copy of ctx carrying the transaction ID. Loggers
+// returned by FromContext are tagged with it.
+func ContextWithTransactionID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, transactionIDCtxKey, id)
+}
+
+// TransactionIDFromContext returns the transaction ID attached to ctx or an empty string
+func TransactionIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(transactionIDCtxKey).(string)
+	return id
+}
+
+// FromContext returns the logger attached to ctx tagged with the context's transaction ID.
+// A StdOutLogger is used if no logger has been attached.
+func FromContext(ctx context.Context) Logger {
+	l, ok := ctx.Value(loggerCtxKey).(Logger)
+	if !ok {
+		l = StdOutLogger{}
+	}
+	return WithTransactionID(l, TransactionIDFromContext(ctx))
+}
+
+// HTTPMiddleware attaches l and a transaction ID to every request's context. The ID is
+// taken from the TransactionIDHeader if the caller sent one, otherwise a new one is
+// generated. Either way it is echoed back on the response.
+func HTTPMiddleware(l Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(TransactionIDHeader)
+		if id == "" {
+			id = NewTransactionID()
+		}
+		w.Header().Set(TransactionIDHeader, id)
+		ctx := ContextWithTransactionID(NewContext(r.Context(), l), id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,78 @@
+package log_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/serendipity-xyz/common/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContextRoundTrip(t *testing.T) {
+	out := new(bytes.Buffer)
+	ctx := log.NewContext(context.Background(), log.NewWriterLogger(out, nil))
+	ctx = log.ContextWithTransactionID(ctx, "mockTrxID")
+	require.Equal(t, "mockTrxID", log.TransactionIDFromContext(ctx), "transaction id")
+	log.FromContext(ctx).Info("hello")
+	require.Contains(t, out.String(), "[trxid: mockTrxID][lvl: info] hello", "logger from context is tagged")
+}
+
+func TestFromContextDefault(t *testing.T) {
+	l := log.FromContext(log.ContextWithTransactionID(context.Background(), "mockTrxID"))
+	require.Equal(t, log.StdOutLogger{TransactionID: "mockTrxID"}, l, "defaults to a tagged StdOutLogger")
+	require.Equal(t, "", log.TransactionIDFromContext(context.Background()), "no transaction id")
+}
+
+func TestStdOutLoggerTransactionIDLines(t *testing.T) {
+	r, w, err := os.Pipe()
+	require.Nil(t, err, "pipe")
+	stdout := os.Stdout
+	os.Stdout = w
+	l := log.StdOutLogger{TransactionID: "mockTrxID"}
+	l.Debug("one")
+	l.Info("two")
+	l.Warn("three")
+	l.Error("four")
+	os.Stdout = stdout
+	w.Close()
+	b, err := ioutil.ReadAll(r)
+	require.Nil(t, err, "read stdout")
+	lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	require.Equal(t, []string{
+		"[trxid: mockTrxID][lvl: debug] one",
+		"[trxid: mockTrxID][lvl: info] two",
+		"[trxid: mockTrxID][lvl: warn] three",
+		"[trxid: mockTrxID][lvl: error] four",
+	}, lines, "one line per call")
+}
+
+func TestHTTPMiddlewareEchoesTransactionID(t *testing.T) {
+	out := new(bytes.Buffer)
+	h := log.HTTPMiddleware(log.NewWriterLogger(out, nil), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.FromContext(r.Context()).Info("handled")
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/activities", nil)
+	req.Header.Set(log.TransactionIDHeader, "mockTrxID")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	require.Equal(t, "mockTrxID", rec.Header().Get(log.TransactionIDHeader), "incoming id is echoed back")
+	require.Contains(t, out.String(), "[trxid: mockTrxID][lvl: info] handled", "handler logs with the incoming id")
+}
+
+func TestHTTPMiddlewareGeneratesTransactionID(t *testing.T) {
+	var fromCtx string
+	h := log.HTTPMiddleware(log.StdOutLogger{}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fromCtx = log.TransactionIDFromContext(r.Context())
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/activities", nil))
+	id := rec.Header().Get(log.TransactionIDHeader)
+	require.Len(t, id, 32, "a new id is generated")
+	require.Equal(t, id, fromCtx, "the generated id is on the request context")
+}
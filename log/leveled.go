@@ -0,0 +1,251 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Level represents the severity of a log line
+type Level int
+
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+)
+
+func (lvl Level) String() string {
+	switch lvl {
+	case DebugLevel:
+		return "debug"
+	case InfoLevel:
+		return "info"
+	case WarnLevel:
+		return "warn"
+	case ErrorLevel:
+		return "error"
+	}
+	return fmt.Sprintf("lvl(%d)", int(lvl))
+}
+
+// ParseLevel converts a level name such as "warn" (case insensitive) into a Level. Useful
+// for reading the minimum level from an environment variable.
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return DebugLevel, nil
+	case "info":
+		return InfoLevel, nil
+	case "warn", "warning":
+		return WarnLevel, nil
+	case "error":
+		return ErrorLevel, nil
+	}
+	return InfoLevel, fmt.Errorf("unknown log level: %q", s)
+}
+
+// keys used for the reserved attributes of a JSON log line
+const (
+	TimeKey          = "time"
+	LevelKey         = "lvl"
+	TransactionIDKey = "trxid"
+	MessageKey       = "msg"
+)
+
+// Fields are key/value pairs attached to a log line
+type Fields map[string]interface{}
+
+// Entry is a single structured log line
+type Entry struct {
+	Time          time.Time
+	Level         Level
+	TransactionID string
+	Message       string
+	Fields        Fields
+}
+
+// Text formats the entry the same way StdOutLogger does, with any fields appended as
+// key=value pairs sorted by key
+func (e Entry) Text() []byte {
+	b := new(bytes.Buffer)
+	b.WriteString(e.Time.Format(time.RFC3339))
+	b.WriteString(" ")
+	if e.TransactionID != "" {
+		fmt.Fprintf(b, "[trxid: %v]", e.TransactionID)
+	}
+	fmt.Fprintf(b, "[lvl: %v] %v", e.Level, e.Message)
+	for _, k := range e.sortedKeys() {
+		fmt.Fprintf(b, " %v=%v", k, textValue(e.Fields[k]))
+	}
+	b.WriteString("\n")
+	return b.Bytes()
+}
+
+// JSON formats the entry as a single line JSON object. Fields are flattened into the top
+// level object; the reserved time, lvl, trxid and msg keys always win over fields with the
+// same name.
+func (e Entry) JSON() []byte {
+	m := make(map[string]interface{}, len(e.Fields)+4)
+	for k, v := range e.Fields {
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		m[k] = v
+	}
+	m[TimeKey] = e.Time.Format(time.RFC3339Nano)
+	m[LevelKey] = e.Level.String()
+	m[MessageKey] = e.Message
+	if e.TransactionID != "" {
+		m[TransactionIDKey] = e.TransactionID
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		// a field could not be marshalled, fall back to the message so the line is not lost
+		b, _ = json.Marshal(map[string]interface{}{
+			TimeKey:          m[TimeKey],
+			LevelKey:         m[LevelKey],
+			TransactionIDKey: e.TransactionID,
+			MessageKey:       e.Message,
+			"logError":       err.Error(),
+		})
+	}
+	return append(b, '\n')
+}
+
+func (e Entry) sortedKeys() []string {
+	keys := make([]string, 0, len(e.Fields))
+	for k := range e.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func textValue(v interface{}) string {
+	s := fmt.Sprintf("%v", v)
+	if strings.ContainsAny(s, " =\"\n\t") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// LeveledLogger is a Logger that drops lines below a minimum level and can attach
+// structured fields to each line. Entries are handed to a Sink which decides where and how
+// they are written.
+type LeveledLogger struct {
+	transactionID string
+	minLevel      Level
+	fields        Fields
+	sink          Sink
+}
+
+type LeveledLoggerParams struct {
+	MinLevel      Level
+	JSON          bool // write one JSON object per line instead of text, ignored if Sink is set
+	TransactionID string
+	Fields        Fields
+	Sink          Sink // defaults to stdout
+}
+
+// NewLeveledLogger returns a logger that writes to params.Sink, or stdout if unset
+func NewLeveledLogger(params *LeveledLoggerParams) *LeveledLogger {
+	if params == nil {
+		params = &LeveledLoggerParams{}
+	}
+	sink := params.Sink
+	if sink == nil {
+		sink = NewWriterSink(os.Stdout, params.JSON)
+	}
+	return &LeveledLogger{
+		transactionID: params.TransactionID,
+		minLevel:      params.MinLevel,
+		fields:        copyFields(params.Fields, len(params.Fields)),
+		sink:          sink,
+	}
+}
+
+// NewWriterLogger returns a logger that writes to w
+func NewWriterLogger(w io.Writer, params *LeveledLoggerParams) *LeveledLogger {
+	p := LeveledLoggerParams{}
+	if params != nil {
+		p = *params
+	}
+	p.Sink = NewWriterSink(w, p.JSON)
+	return NewLeveledLogger(&p)
+}
+
+// NewMultiLogger returns a logger that fans out to all of the given sinks. Wrap a sink with
+// MinLevelSink to give it its own minimum level, e.g. verbose stdout with only warnings
+// upstream:
+//
+//	log.NewMultiLogger(nil,
+//		log.NewWriterSink(os.Stdout, false),
+//		log.MinLevelSink(log.WarnLevel, log.NewWriterSink(upstream, true)),
+//	)
+func NewMultiLogger(params *LeveledLoggerParams, sinks ...Sink) *LeveledLogger {
+	p := LeveledLoggerParams{}
+	if params != nil {
+		p = *params
+	}
+	p.Sink = MultiSink(sinks...)
+	return NewLeveledLogger(&p)
+}
+
+// WithFields returns a child logger that adds the given fields to every line. The
+// receiver is left untouched.
+func (l *LeveledLogger) WithFields(fields Fields) *LeveledLogger {
+	child := *l
+	child.fields = copyFields(l.fields, len(l.fields)+len(fields))
+	for k, v := range fields {
+		child.fields[k] = v
+	}
+	return &child
+}
+
+// WithField is shorthand for WithFields with a single key/value pair
+func (l *LeveledLogger) WithField(key string, value interface{}) *LeveledLogger {
+	return l.WithFields(Fields{key: value})
+}
+
+// Enabled reports whether lines at the given level will be written
+func (l *LeveledLogger) Enabled(lvl Level) bool {
+	return lvl >= l.minLevel
+}
+
+func (l *LeveledLogger) Debug(s string, a ...interface{}) { l.log(DebugLevel, s, a...) }
+
+func (l *LeveledLogger) Info(s string, a ...interface{}) { l.log(InfoLevel, s, a...) }
+
+func (l *LeveledLogger) Warn(s string, a ...interface{}) { l.log(WarnLevel, s, a...) }
+
+func (l *LeveledLogger) Error(s string, a ...interface{}) { l.log(ErrorLevel, s, a...) }
+
+func (l *LeveledLogger) log(lvl Level, s string, a ...interface{}) {
+	if !l.Enabled(lvl) {
+		return
+	}
+	e := Entry{
+		Time:          time.Now().UTC(),
+		Level:         lvl,
+		TransactionID: l.transactionID,
+		Message:       fmt.Sprintf(s, a...),
+		Fields:        l.fields,
+	}
+	l.sink.Write(e)
+}
+
+func copyFields(fields Fields, size int) Fields {
+	c := make(Fields, size)
+	for k, v := range fields {
+		c[k] = v
+	}
+	return c
+}
@@ -9,14 +9,15 @@ type Logger interface {
 	Error(string, ...interface{})
 }
 
+// StdOutLogger prints every line regardless of level. Use LeveledLogger when lines need
+// to be filtered by level or shipped as JSON.
 type StdOutLogger struct {
 	TransactionID string
-	// LogLvl // @todo support different log lvls
 }
 
 func (l StdOutLogger) Debug(s string, a ...interface{}) {
 	if l.TransactionID != "" {
-		fmt.Printf("[trxid: %v][lvl: debug] %v", l.TransactionID, fmt.Sprintf(s, a...))
+		fmt.Printf("[trxid: %v][lvl: debug] %v\n", l.TransactionID, fmt.Sprintf(s, a...))
 		return
 	}
 	fmt.Printf(s, a...)
@@ -25,7 +26,7 @@ func (l StdOutLogger) Debug(s string, a ...interface{}) {
 
 func (l StdOutLogger) Info(s string, a ...interface{}) {
 	if l.TransactionID != "" {
-		fmt.Printf("[trxid: %v][lvl: info] %v", l.TransactionID, fmt.Sprintf(s, a...))
+		fmt.Printf("[trxid: %v][lvl: info] %v\n", l.TransactionID, fmt.Sprintf(s, a...))
 		return
 	}
 	fmt.Printf(s, a...)
@@ -34,7 +35,7 @@ func (l StdOutLogger) Info(s string, a ...interface{}) {
 
 func (l StdOutLogger) Warn(s string, a ...interface{}) {
 	if l.TransactionID != "" {
-		fmt.Printf("[trxid: %v][lvl: warn] %v", l.TransactionID, fmt.Sprintf(s, a...))
+		fmt.Printf("[trxid: %v][lvl: warn] %v\n", l.TransactionID, fmt.Sprintf(s, a...))
 		return
 	}
 	fmt.Printf(s, a...)
@@ -43,9 +44,23 @@ func (l StdOutLogger) Warn(s string, a ...interface{}) {
 
 func (l StdOutLogger) Error(s string, a ...interface{}) {
 	if l.TransactionID != "" {
-		fmt.Printf("[trxid: %v][lvl: error] %v", l.TransactionID, fmt.Sprintf(s, a...))
+		fmt.Printf("[trxid: %v][lvl: error] %v\n", l.TransactionID, fmt.Sprintf(s, a...))
 		return
 	}
 	fmt.Printf(s, a...)
 	fmt.Println()
 }
+
+// logAt writes an already formatted message to l at the given level
+func logAt(l Logger, lvl Level, msg string) {
+	switch lvl {
+	case DebugLevel:
+		l.Debug("%s", msg)
+	case InfoLevel:
+		l.Info("%s", msg)
+	case WarnLevel:
+		l.Warn("%s", msg)
+	default:
+		l.Error("%s", msg)
+	}
+}
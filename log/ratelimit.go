@@ -0,0 +1,142 @@
+package log
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// maxTrackedMessages bounds memory if callers log preformatted, always unique, strings
+const maxTrackedMessages = 1024
+
+type RateLimitedLoggerParams struct {
+	Window time.Duration // defaults to a minute
+	Burst  int           // identical lines let through per window, defaults to 1
+}
+
+type messageCounter struct {
+	lvl         Level
+	windowStart time.Time
+	seen        int
+	suppressed  int
+	last        string
+}
+
+type rateLimitState struct {
+	mu       sync.Mutex
+	counters map[string]*messageCounter
+}
+
+type summary struct {
+	lvl     Level
+	message string
+}
+
+// RateLimitedLogger wraps a Logger and lets through at most Burst lines per Window for each
+// level and format string. Formatting arguments are ignored when deciding what counts as a
+// repeat, so "failed to fetch sqs message %v" is limited regardless of the error. Once a
+// window ends the next line for that message is preceded by a summary such as
+// "failed to fetch sqs message timeout (logged 240 times in the last 1m0s)".
+type RateLimitedLogger struct {
+	l      Logger
+	window time.Duration
+	burst  int
+	state  *rateLimitState
+}
+
+// NewRateLimitedLogger wraps l. Pass nil params for one line per message per minute.
+func NewRateLimitedLogger(l Logger, params *RateLimitedLoggerParams) *RateLimitedLogger {
+	p := RateLimitedLoggerParams{}
+	if params != nil {
+		p = *params
+	}
+	if p.Window <= 0 {
+		p.Window = time.Minute
+	}
+	if p.Burst <= 0 {
+		p.Burst = 1
+	}
+	return &RateLimitedLogger{
+		l:      l,
+		window: p.Window,
+		burst:  p.Burst,
+		state:  &rateLimitState{counters: map[string]*messageCounter{}},
+	}
+}
+
+func (rl *RateLimitedLogger) Debug(s string, a ...interface{}) { rl.log(DebugLevel, s, a...) }
+
+func (rl *RateLimitedLogger) Info(s string, a ...interface{}) { rl.log(InfoLevel, s, a...) }
+
+func (rl *RateLimitedLogger) Warn(s string, a ...interface{}) { rl.log(WarnLevel, s, a...) }
+
+func (rl *RateLimitedLogger) Error(s string, a ...interface{}) { rl.log(ErrorLevel, s, a...) }
+
+// WithTransactionID tags the wrapped logger. The child shares its limits with the parent.
+func (rl *RateLimitedLogger) WithTransactionID(id string) Logger {
+	child := *rl
+	child.l = WithTransactionID(rl.l, id)
+	return &child
+}
+
+// Flush writes a summary for every message that has been suppressed in its current window
+func (rl *RateLimitedLogger) Flush() {
+	rl.state.mu.Lock()
+	var pending []summary
+	now := time.Now()
+	for key, c := range rl.state.counters {
+		if c.suppressed > 0 {
+			pending = append(pending, summary{lvl: c.lvl, message: rl.summarise(c, now)})
+		}
+		delete(rl.state.counters, key)
+	}
+	rl.state.mu.Unlock()
+	for _, s := range pending {
+		logAt(rl.l, s.lvl, s.message)
+	}
+}
+
+func (rl *RateLimitedLogger) log(lvl Level, s string, a ...interface{}) {
+	key := fmt.Sprintf("%d|%v", lvl, s)
+	msg := fmt.Sprintf(s, a...)
+	now := time.Now()
+
+	rl.state.mu.Lock()
+	var pending *summary
+	c, ok := rl.state.counters[key]
+	if ok && now.Sub(c.windowStart) >= rl.window {
+		if c.suppressed > 0 {
+			pending = &summary{lvl: lvl, message: rl.summarise(c, now)}
+		}
+		ok = false
+	}
+	if !ok {
+		if len(rl.state.counters) >= maxTrackedMessages {
+			rl.state.counters = map[string]*messageCounter{}
+		}
+		c = &messageCounter{lvl: lvl, windowStart: now}
+		rl.state.counters[key] = c
+	}
+	c.seen++
+	allowed := c.seen <= rl.burst
+	if !allowed {
+		c.suppressed++
+		c.last = msg
+	}
+	rl.state.mu.Unlock()
+
+	if pending != nil {
+		logAt(rl.l, pending.lvl, pending.message)
+	}
+	if allowed {
+		logAt(rl.l, lvl, msg)
+	}
+}
+
+func (rl *RateLimitedLogger) summarise(c *messageCounter, now time.Time) string {
+	elapsed := now.Sub(c.windowStart)
+	if elapsed > rl.window {
+		elapsed = rl.window
+	}
+	return fmt.Sprintf("%v (logged %v times in the last %v)", c.last, c.seen, elapsed.Round(time.Millisecond))
+}
@@ -0,0 +1,156 @@
+package log
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// RedactedValue replaces anything a Redactor considers secret
+const RedactedValue = "[REDACTED]"
+
+// DefaultSecretParams are the query parameter / JSON field names masked by DefaultRedactors
+var DefaultSecretParams = []string{"client_secret", "refresh_token", "access_token", "code", "password"}
+
+// Redactor masks secrets in a string
+type Redactor interface {
+	Redact(s string) string
+}
+
+// RedactorFunc adapts a function to a Redactor
+type RedactorFunc func(s string) string
+
+func (f RedactorFunc) Redact(s string) string { return f(s) }
+
+type regexpRedactor struct {
+	re          *regexp.Regexp
+	replacement string
+}
+
+// RegexpRedactor replaces every match of re with replacement, which may reference
+// submatches the same way regexp.ReplaceAllString does
+func RegexpRedactor(re *regexp.Regexp, replacement string) Redactor {
+	return regexpRedactor{re: re, replacement: replacement}
+}
+
+func (r regexpRedactor) Redact(s string) string {
+	return r.re.ReplaceAllString(s, r.replacement)
+}
+
+func quoteNames(names []string) string {
+	quoted := make([]string, len(names))
+	for i, n := range names {
+		quoted[i] = regexp.QuoteMeta(n)
+	}
+	return strings.Join(quoted, "|")
+}
+
+// QueryParamRedactor masks the values of the named query (or form) parameters, e.g.
+// ?client_secret=abc&scope=read becomes ?client_secret=[REDACTED]&scope=read
+func QueryParamRedactor(names ...string) Redactor {
+	re := regexp.MustCompile(fmt.Sprintf(`(?i)\b(%v)=[^&\s"'#]*`, quoteNames(names)))
+	return RegexpRedactor(re, "${1}="+RedactedValue)
+}
+
+// JSONFieldRedactor masks the string values of the named JSON fields, e.g.
+// {"access_token": "abc"} becomes {"access_token": "[REDACTED]"}
+func JSONFieldRedactor(names ...string) Redactor {
+	re := regexp.MustCompile(fmt.Sprintf(`(?i)("(?:%v)"\s*:\s*)"(?:[^"\\]|\\.)*"`, quoteNames(names)))
+	return RegexpRedactor(re, `${1}"`+RedactedValue+`"`)
+}
+
+// BearerTokenRedactor masks bearer tokens such as those in an Authorization header
+func BearerTokenRedactor() Redactor {
+	re := regexp.MustCompile(`(?i)\b(bearer)\s+[A-Za-z0-9\-._~+/]+=*`)
+	return RegexpRedactor(re, "${1} "+RedactedValue)
+}
+
+// MongoURIRedactor masks the password in mongodb:// and mongodb+srv:// connection strings
+func MongoURIRedactor() Redactor {
+	re := regexp.MustCompile(`(mongodb(?:\+srv)?://[^:/@\s]+):[^@\s]+@`)
+	return RegexpRedactor(re, "${1}:"+RedactedValue+"@")
+}
+
+// DefaultRedactors masks DefaultSecretParams in query strings and JSON, bearer tokens and
+// passwords in mongo URIs
+func DefaultRedactors() []Redactor {
+	return []Redactor{
+		QueryParamRedactor(DefaultSecretParams...),
+		JSONFieldRedactor(DefaultSecretParams...),
+		BearerTokenRedactor(),
+		MongoURIRedactor(),
+	}
+}
+
+// Redact runs s through each of the redactors in turn
+func Redact(s string, redactors ...Redactor) string {
+	for _, r := range redactors {
+		s = r.Redact(s)
+	}
+	return s
+}
+
+// RedactingLogger wraps a Logger and masks secrets in every formatted message before the
+// wrapped logger sees it
+type RedactingLogger struct {
+	l         Logger
+	redactors []Redactor
+}
+
+// NewRedactingLogger wraps l. DefaultRedactors are used if none are given.
+func NewRedactingLogger(l Logger, redactors ...Redactor) *RedactingLogger {
+	if len(redactors) == 0 {
+		redactors = DefaultRedactors()
+	}
+	return &RedactingLogger{l: l, redactors: redactors}
+}
+
+func (rl *RedactingLogger) Debug(s string, a ...interface{}) { rl.log(DebugLevel, s, a...) }
+
+func (rl *RedactingLogger) Info(s string, a ...interface{}) { rl.log(InfoLevel, s, a...) }
+
+func (rl *RedactingLogger) Warn(s string, a ...interface{}) { rl.log(WarnLevel, s, a...) }
+
+func (rl *RedactingLogger) Error(s string, a ...interface{}) { rl.log(ErrorLevel, s, a...) }
+
+func (rl *RedactingLogger) WithTransactionID(id string) Logger {
+	child := *rl
+	child.l = WithTransactionID(rl.l, id)
+	return &child
+}
+
+func (rl *RedactingLogger) log(lvl Level, s string, a ...interface{}) {
+	logAt(rl.l, lvl, Redact(fmt.Sprintf(s, a...), rl.redactors...))
+}
+
+type redactingSink struct {
+	sink      Sink
+	redactors []Redactor
+}
+
+// RedactingSink masks secrets in the message and in string or error field values of every
+// entry before passing it on. DefaultRedactors are used if none are given.
+func RedactingSink(s Sink, redactors ...Redactor) Sink {
+	if len(redactors) == 0 {
+		redactors = DefaultRedactors()
+	}
+	return redactingSink{sink: s, redactors: redactors}
+}
+
+func (rs redactingSink) Write(e Entry) error {
+	e.Message = Redact(e.Message, rs.redactors...)
+	if len(e.Fields) > 0 {
+		fields := make(Fields, len(e.Fields))
+		for k, v := range e.Fields {
+			switch val := v.(type) {
+			case string:
+				v = Redact(val, rs.redactors...)
+			case error:
+				v = Redact(val.Error(), rs.redactors...)
+			}
+			fields[k] = v
+		}
+		e.Fields = fields
+	}
+	return rs.sink.Write(e)
+}
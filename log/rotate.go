@@ -0,0 +1,306 @@
+package log
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const backupTimeFormat = "2006-01-02T15-04-05.000000000"
+
+// RotatingFile is an io.Writer backed by a file on disk. The file is rotated when a write
+// would take it past MaxSize bytes or, if Daily is set, when the day changes. Rotated files
+// are gzipped next to the original (worker.log becomes worker-<timestamp>.log.gz) and only
+// the newest MaxBackups are kept. It is safe for concurrent use.
+type RotatingFile struct {
+	mu            sync.Mutex
+	path          string
+	maxSize       int64
+	daily         bool
+	maxBackups    int
+	now           func() time.Time
+	onBackupError func(err error)
+	file          *os.File // nil after a failed rotation until it is reopened
+	closed        bool
+	size          int64
+	day           string
+}
+
+type RotatingFileParams struct {
+	Path       string
+	MaxSize    int64 // in bytes, 0 disables size based rotation
+	Daily      bool  // rotate on the first write of a new (UTC) day
+	MaxBackups int   // number of backups to keep, 0 keeps all of them
+	// OnBackupError is called when rotating, compressing or pruning backups fails during a
+	// write. The write itself still goes to the current file. Defaults to printing to stderr.
+	OnBackupError func(err error)
+	Now           func() time.Time // defaults to time.Now
+}
+
+// NewRotatingFile opens (or creates) the file at params.Path for appending
+func NewRotatingFile(params *RotatingFileParams) (*RotatingFile, error) {
+	if params == nil || params.Path == "" {
+		return nil, errors.New("rotating file requires a path")
+	}
+	f := &RotatingFile{
+		path:          params.Path,
+		maxSize:       params.MaxSize,
+		daily:         params.Daily,
+		maxBackups:    params.MaxBackups,
+		now:           params.Now,
+		onBackupError: params.OnBackupError,
+	}
+	if f.now == nil {
+		f.now = time.Now
+	}
+	if f.onBackupError == nil {
+		f.onBackupError = func(err error) {
+			fmt.Fprintf(os.Stderr, "rotating log file: %v\n", err)
+		}
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *RotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return fmt.Errorf("unable to create log directory: %v", err)
+	}
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to open log file: %v", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("unable to stat log file: %v", err)
+	}
+	f.file = file
+	f.size = info.Size()
+	f.day = dayOf(f.now())
+	if f.size > 0 {
+		// an existing file belongs to the day it was last written
+		f.day = dayOf(info.ModTime())
+	}
+	return nil
+}
+
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.ensureOpen(); err != nil {
+		return 0, err
+	}
+	var backupErr error
+	if f.shouldRotate(int64(len(p))) {
+		backup, err := f.rotate()
+		if err != nil && f.file == nil {
+			return 0, err
+		}
+		if err != nil {
+			backupErr = err // the current file was reopened, rotation is tried again next write
+		} else {
+			backupErr = f.backup(backup)
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	if backupErr != nil {
+		// the line still made it to a file, a failed rotation or backup must not lose it
+		f.onBackupError(backupErr)
+	}
+	return n, err
+}
+
+func (f *RotatingFile) shouldRotate(incoming int64) bool {
+	if f.size == 0 {
+		return false
+	}
+	if f.maxSize > 0 && f.size+incoming > f.maxSize {
+		return true
+	}
+	return f.daily && dayOf(f.now()) != f.day
+}
+
+// Rotate forces a rotation regardless of size or day
+func (f *RotatingFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.ensureOpen(); err != nil {
+		return err
+	}
+	backup, err := f.rotate()
+	if err != nil {
+		return err
+	}
+	return f.backup(backup)
+}
+
+// Close closes the underlying file. Further writes fail.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// ensureOpen reopens the file if an earlier rotation could not
+func (f *RotatingFile) ensureOpen() error {
+	if f.closed {
+		return os.ErrClosed
+	}
+	if f.file == nil {
+		return f.open()
+	}
+	return nil
+}
+
+// rotate moves the current file aside and opens a new one, returning the path it was
+// moved to
+func (f *RotatingFile) rotate() (string, error) {
+	err := f.file.Close()
+	f.file = nil
+	if err != nil {
+		return "", f.reopen(fmt.Errorf("unable to close log file: %v", err))
+	}
+	ext := filepath.Ext(f.path)
+	backup := fmt.Sprintf("%v-%v%v", strings.TrimSuffix(f.path, ext), f.now().UTC().Format(backupTimeFormat), ext)
+	if err := os.Rename(f.path, backup); err != nil {
+		return "", f.reopen(fmt.Errorf("unable to rename log file: %v", err))
+	}
+	return backup, f.open()
+}
+
+// reopen opens the current file again after a failed rotation so logging carries on, and
+// returns err
+func (f *RotatingFile) reopen(err error) error {
+	if openErr := f.open(); openErr != nil {
+		return fmt.Errorf("%v, %v", err, openErr)
+	}
+	return err
+}
+
+// backup compresses a rotated file and prunes old backups
+func (f *RotatingFile) backup(path string) error {
+	if err := compress(path); err != nil {
+		return err
+	}
+	return f.prune()
+}
+
+func (f *RotatingFile) backups() ([]string, error) {
+	ext := filepath.Ext(f.path)
+	prefix := filepath.Base(strings.TrimSuffix(f.path, ext)) + "-"
+	entries, err := os.ReadDir(filepath.Dir(f.path))
+	if err != nil {
+		return nil, err
+	}
+	var res []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		// backups that failed to compress are kept uncompressed and count too
+		stamp := strings.TrimPrefix(name, prefix)
+		if strings.HasSuffix(stamp, ext+".gz") {
+			stamp = strings.TrimSuffix(stamp, ext+".gz")
+		} else if strings.HasSuffix(stamp, ext) {
+			stamp = strings.TrimSuffix(stamp, ext)
+		} else {
+			continue
+		}
+		// only our own backups, not e.g. worker-foo.log.gz next to worker.log
+		if _, err := time.Parse(backupTimeFormat, stamp); err == nil {
+			res = append(res, filepath.Join(filepath.Dir(f.path), name))
+		}
+	}
+	sort.Strings(res) // timestamps sort lexically, oldest first
+	return res, nil
+}
+
+func (f *RotatingFile) prune() error {
+	if f.maxBackups <= 0 {
+		return nil
+	}
+	backups, err := f.backups()
+	if err != nil {
+		return fmt.Errorf("unable to list log backups: %v", err)
+	}
+	for len(backups) > f.maxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			return fmt.Errorf("unable to remove old log backup: %v", err)
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+func compress(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("unable to open log backup: %v", err)
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to create compressed log backup: %v", err)
+	}
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		dst.Close()
+		return fmt.Errorf("unable to compress log backup: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		dst.Close()
+		return fmt.Errorf("unable to compress log backup: %v", err)
+	}
+	if err := dst.Close(); err != nil {
+		return fmt.Errorf("unable to compress log backup: %v", err)
+	}
+	return os.Remove(path)
+}
+
+func dayOf(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+// FileSink is a Sink that writes to a RotatingFile
+type FileSink struct {
+	file *RotatingFile
+	sink Sink
+}
+
+// NewFileSink opens a rotating file and returns a sink writing text, or JSON if json is
+// true, to it. Close the sink on shutdown to flush the file.
+func NewFileSink(params *RotatingFileParams, json bool) (*FileSink, error) {
+	file, err := NewRotatingFile(params)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{
+		file: file,
+		sink: NewWriterSink(file, json),
+	}, nil
+}
+
+func (s *FileSink) Write(e Entry) error { return s.sink.Write(e) }
+
+// File returns the underlying rotating file, e.g. to force a rotation
+func (s *FileSink) File() *RotatingFile { return s.file }
+
+func (s *FileSink) Close() error { return s.file.Close() }
@@ -0,0 +1,238 @@
+package log_test
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/serendipity-xyz/common/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotateBySize(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "worker.log")
+	f, err := log.NewRotatingFile(&log.RotatingFileParams{
+		Path:       path,
+		MaxSize:    10,
+		MaxBackups: 2,
+	})
+	require.Nil(t, err, "no error opening file")
+	defer f.Close()
+	for _, line := range []string{"line one\n", "line two\n", "line three\n", "line four\n"} {
+		_, err := f.Write([]byte(line))
+		require.Nil(t, err, "no error writing")
+	}
+	current, err := ioutil.ReadFile(path)
+	require.Nil(t, err, "no error reading current file")
+	require.Equal(t, "line four\n", string(current), "current file only has the latest line")
+
+	backups, err := filepath.Glob(filepath.Join(dir, "worker-*.log.gz"))
+	require.Nil(t, err, "no error listing backups")
+	require.Len(t, backups, 2, "only two backups kept")
+	gz, err := os.Open(backups[1])
+	require.Nil(t, err, "no error opening backup")
+	defer gz.Close()
+	r, err := gzip.NewReader(gz)
+	require.Nil(t, err, "backup is gzipped")
+	content, err := ioutil.ReadAll(r)
+	require.Nil(t, err, "no error reading backup")
+	require.Equal(t, "line three\n", string(content), "newest backup has the previous line")
+}
+
+func TestFileSinkConcurrentWrites(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := log.NewFileSink(&log.RotatingFileParams{
+		Path:    filepath.Join(dir, "worker.log"),
+		MaxSize: 1024,
+	}, false)
+	require.Nil(t, err, "no error opening sink")
+	l := log.NewLeveledLogger(&log.LeveledLoggerParams{Sink: sink})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				l.Info("worker %v line %v", i, j)
+			}
+		}(i)
+	}
+	wg.Wait()
+	require.Nil(t, sink.Close(), "no error closing")
+
+	lines := 0
+	files, _ := filepath.Glob(filepath.Join(dir, "worker*"))
+	for _, name := range files {
+		content := readMaybeGzipped(t, name)
+		for _, line := range strings.Split(strings.TrimSpace(content), "\n") {
+			require.Contains(t, line, "[lvl: info] worker", "lines are not interleaved")
+			lines++
+		}
+	}
+	require.Equal(t, 200, lines, "no lines lost across rotations")
+}
+
+func readMaybeGzipped(t *testing.T, name string) string {
+	f, err := os.Open(name)
+	require.Nil(t, err, "no error opening %v", name)
+	defer f.Close()
+	if !strings.HasSuffix(name, ".gz") {
+		b, err := ioutil.ReadAll(f)
+		require.Nil(t, err, "no error reading %v", name)
+		return string(b)
+	}
+	r, err := gzip.NewReader(f)
+	require.Nil(t, err, "no error opening %v", name)
+	b, err := ioutil.ReadAll(r)
+	require.Nil(t, err, "no error reading %v", name)
+	return string(b)
+}
+
+func TestRotateDaily(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "worker.log")
+	now := time.Date(2023, 3, 1, 23, 59, 0, 0, time.UTC)
+	f, err := log.NewRotatingFile(&log.RotatingFileParams{
+		Path:  path,
+		Daily: true,
+		Now:   func() time.Time { return now },
+	})
+	require.Nil(t, err, "no error opening file")
+	defer f.Close()
+	_, err = f.Write([]byte("monday\n"))
+	require.Nil(t, err, "no error writing")
+	now = now.Add(30 * time.Second)
+	_, err = f.Write([]byte("still monday\n"))
+	require.Nil(t, err, "no error writing")
+	backups, _ := filepath.Glob(filepath.Join(dir, "worker-*.log.gz"))
+	require.Empty(t, backups, "no rotation within a day")
+
+	now = now.Add(time.Minute)
+	_, err = f.Write([]byte("tuesday\n"))
+	require.Nil(t, err, "no error writing")
+	current, err := ioutil.ReadFile(path)
+	require.Nil(t, err, "no error reading current file")
+	require.Equal(t, "tuesday\n", string(current), "new day starts a new file")
+	backup := filepath.Join(dir, "worker-2023-03-02T00-00-30.000000000.log.gz")
+	require.Equal(t, "monday\nstill monday\n", readMaybeGzipped(t, backup), "yesterday is backed up")
+}
+
+func TestRotateKeepsLineWhenBackupFails(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "worker.log")
+	now := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	var backupErrs []error
+	f, err := log.NewRotatingFile(&log.RotatingFileParams{
+		Path:          path,
+		MaxSize:       10,
+		Now:           func() time.Time { return now },
+		OnBackupError: func(err error) { backupErrs = append(backupErrs, err) },
+	})
+	require.Nil(t, err, "no error opening file")
+	defer f.Close()
+	// a directory where the compressed backup should go makes compression fail
+	require.Nil(t, os.Mkdir(filepath.Join(dir, "worker-2023-03-01T12-00-00.000000000.log.gz"), 0755), "no error blocking backup")
+
+	_, err = f.Write([]byte("line one\n"))
+	require.Nil(t, err, "no error writing")
+	n, err := f.Write([]byte("line two\n"))
+	require.Nil(t, err, "a failed backup does not fail the write")
+	require.Equal(t, 9, n, "whole line written")
+	require.Len(t, backupErrs, 1, "backup error is reported")
+	current, err := ioutil.ReadFile(path)
+	require.Nil(t, err, "no error reading current file")
+	require.Equal(t, "line two\n", string(current), "line is not lost")
+}
+
+func TestRotateRecoversWhenRenameFails(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "worker.log")
+	now := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	var backupErrs []error
+	f, err := log.NewRotatingFile(&log.RotatingFileParams{
+		Path:          path,
+		MaxSize:       10,
+		Now:           func() time.Time { return now },
+		OnBackupError: func(err error) { backupErrs = append(backupErrs, err) },
+	})
+	require.Nil(t, err, "no error opening file")
+	defer f.Close()
+	// a non empty directory where the rotated file should go makes the rename fail
+	blocked := filepath.Join(dir, "worker-2023-03-01T12-00-00.000000000.log")
+	require.Nil(t, os.MkdirAll(filepath.Join(blocked, "x"), 0755), "no error blocking rename")
+
+	_, err = f.Write([]byte("line one\n"))
+	require.Nil(t, err, "no error writing")
+	_, err = f.Write([]byte("line two\n"))
+	require.Nil(t, err, "a failed rename does not fail the write")
+	require.Len(t, backupErrs, 1, "rename error is reported")
+	require.Contains(t, backupErrs[0].Error(), "unable to rename log file", "rename error")
+	current, err := ioutil.ReadFile(path)
+	require.Nil(t, err, "no error reading current file")
+	require.Equal(t, "line one\nline two\n", string(current), "current file kept")
+
+	require.Nil(t, os.RemoveAll(blocked), "no error unblocking rename")
+	now = now.Add(time.Second)
+	_, err = f.Write([]byte("line three\n"))
+	require.Nil(t, err, "no error writing")
+	current, err = ioutil.ReadFile(path)
+	require.Nil(t, err, "no error reading current file")
+	require.Equal(t, "line three\n", string(current), "rotation works again")
+	backup := filepath.Join(dir, "worker-2023-03-01T12-00-01.000000000.log.gz")
+	require.Equal(t, "line one\nline two\n", readMaybeGzipped(t, backup), "old lines backed up")
+}
+
+func TestPruneUncompressedBackups(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	f, err := log.NewRotatingFile(&log.RotatingFileParams{
+		Path:          filepath.Join(dir, "worker.log"),
+		MaxSize:       10,
+		MaxBackups:    1,
+		Now:           func() time.Time { return now },
+		OnBackupError: func(err error) {},
+	})
+	require.Nil(t, err, "no error opening file")
+	defer f.Close()
+	// the first backup can't be compressed and stays as worker-<timestamp>.log
+	require.Nil(t, os.Mkdir(filepath.Join(dir, "worker-2023-03-01T12-00-01.000000000.log.gz"), 0755), "no error blocking backup")
+	for _, line := range []string{"line one\n", "line two\n", "line three\n"} {
+		_, err := f.Write([]byte(line))
+		require.Nil(t, err, "no error writing")
+		now = now.Add(time.Second)
+	}
+	_, err = os.Stat(filepath.Join(dir, "worker-2023-03-01T12-00-01.000000000.log"))
+	require.True(t, os.IsNotExist(err), "uncompressed backup pruned")
+	backups, _ := filepath.Glob(filepath.Join(dir, "worker-2*.log*"))
+	require.Equal(t, []string{
+		filepath.Join(dir, "worker-2023-03-01T12-00-01.000000000.log.gz"), // the blocking directory
+		filepath.Join(dir, "worker-2023-03-01T12-00-02.000000000.log.gz"),
+	}, backups, "only the newest backup kept")
+}
+
+func TestPruneOnlyOwnBackups(t *testing.T) {
+	dir := t.TempDir()
+	other := filepath.Join(dir, "worker-foo.log.gz")
+	require.Nil(t, ioutil.WriteFile(other, []byte("not ours"), 0644), "no error writing other file")
+	f, err := log.NewRotatingFile(&log.RotatingFileParams{
+		Path:       filepath.Join(dir, "worker.log"),
+		MaxSize:    10,
+		MaxBackups: 1,
+	})
+	require.Nil(t, err, "no error opening file")
+	defer f.Close()
+	for _, line := range []string{"line one\n", "line two\n", "line three\n"} {
+		_, err := f.Write([]byte(line))
+		require.Nil(t, err, "no error writing")
+	}
+	_, err = os.Stat(other)
+	require.Nil(t, err, "worker-foo.log.gz is left alone")
+	backups, _ := filepath.Glob(filepath.Join(dir, "worker-2*.log.gz"))
+	require.Len(t, backups, 1, "own backups are pruned")
+}
@@ -0,0 +1,114 @@
+package log
+
+import (
+	"io"
+	"sync"
+)
+
+// Sink is a destination for log entries. A LeveledLogger formats nothing itself, it hands
+// every entry at or above its minimum level to its sink.
+type Sink interface {
+	Write(e Entry) error
+}
+
+type writerSink struct {
+	w    io.Writer
+	json bool
+	mu   sync.Mutex
+}
+
+// NewWriterSink returns a sink that writes each entry to w as a line of text, or as a JSON
+// object if json is true. Writes are serialised so w does not need to be safe for
+// concurrent use.
+func NewWriterSink(w io.Writer, json bool) Sink {
+	return &writerSink{w: w, json: json}
+}
+
+func (s *writerSink) Write(e Entry) error {
+	var line []byte
+	if s.json {
+		line = e.JSON()
+	} else {
+		line = e.Text()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.w.Write(line)
+	return err
+}
+
+type minLevelSink struct {
+	min  Level
+	sink Sink
+}
+
+// MinLevelSink only passes on entries at or above min. Combine with MultiSink to give
+// each destination its own level.
+func MinLevelSink(min Level, s Sink) Sink {
+	return minLevelSink{min: min, sink: s}
+}
+
+func (s minLevelSink) Write(e Entry) error {
+	if e.Level < s.min {
+		return nil
+	}
+	return s.sink.Write(e)
+}
+
+type multiSink []Sink
+
+// MultiSink fans every entry out to all of the given sinks. A failing sink does not stop
+// the others from receiving the entry; the first error is returned.
+func MultiSink(sinks ...Sink) Sink {
+	return multiSink(sinks)
+}
+
+func (ms multiSink) Write(e Entry) error {
+	var firstErr error
+	for _, s := range ms {
+		if err := s.Write(e); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// RingBuffer is an in-memory sink that keeps the most recent entries. Handy for exposing
+// recent logs on a debug endpoint or dumping them when a job fails.
+type RingBuffer struct {
+	mu      sync.Mutex
+	entries []Entry
+	next    int
+	full    bool
+}
+
+// NewRingBuffer returns a ring buffer holding at most size entries
+func NewRingBuffer(size int) *RingBuffer {
+	if size < 1 {
+		size = 1
+	}
+	return &RingBuffer{entries: make([]Entry, size)}
+}
+
+func (rb *RingBuffer) Write(e Entry) error {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	rb.entries[rb.next] = e
+	rb.next = (rb.next + 1) % len(rb.entries)
+	if rb.next == 0 {
+		rb.full = true
+	}
+	return nil
+}
+
+// Entries returns a copy of the buffered entries, oldest first
+func (rb *RingBuffer) Entries() []Entry {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	if !rb.full {
+		return append([]Entry(nil), rb.entries[:rb.next]...)
+	}
+	res := make([]Entry, 0, len(rb.entries))
+	res = append(res, rb.entries[rb.next:]...)
+	return append(res, rb.entries[:rb.next]...)
+}
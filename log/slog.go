@@ -0,0 +1,175 @@
+//go:build go1.21
+
+package log
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// SlogLogger is a Logger backed by a slog.Handler, for passing a slog setup into packages
+// that take a Logger. The transaction ID is added as a TransactionIDKey attribute.
+type SlogLogger struct {
+	h             slog.Handler
+	transactionID string
+}
+
+func NewSlogLogger(h slog.Handler) *SlogLogger {
+	return &SlogLogger{h: h}
+}
+
+func (l *SlogLogger) Debug(s string, a ...interface{}) { l.log(DebugLevel, s, a...) }
+
+func (l *SlogLogger) Info(s string, a ...interface{}) { l.log(InfoLevel, s, a...) }
+
+func (l *SlogLogger) Warn(s string, a ...interface{}) { l.log(WarnLevel, s, a...) }
+
+func (l *SlogLogger) Error(s string, a ...interface{}) { l.log(ErrorLevel, s, a...) }
+
+func (l *SlogLogger) WithTransactionID(id string) Logger {
+	child := *l
+	child.transactionID = id
+	return &child
+}
+
+func (l *SlogLogger) log(lvl Level, s string, a ...interface{}) {
+	ctx := context.Background()
+	sl := toSlogLevel(lvl)
+	if !l.h.Enabled(ctx, sl) {
+		return
+	}
+	r := slog.NewRecord(time.Now(), sl, fmt.Sprintf(s, a...), 0)
+	if l.transactionID != "" {
+		r.AddAttrs(slog.String(TransactionIDKey, l.transactionID))
+	}
+	l.h.Handle(ctx, r)
+}
+
+func toSlogLevel(lvl Level) slog.Level {
+	switch lvl {
+	case DebugLevel:
+		return slog.LevelDebug
+	case InfoLevel:
+		return slog.LevelInfo
+	case WarnLevel:
+		return slog.LevelWarn
+	}
+	return slog.LevelError
+}
+
+func fromSlogLevel(lvl slog.Level) Level {
+	switch {
+	case lvl < slog.LevelInfo:
+		return DebugLevel
+	case lvl < slog.LevelWarn:
+		return InfoLevel
+	case lvl < slog.LevelError:
+		return WarnLevel
+	}
+	return ErrorLevel
+}
+
+type slogHandler struct {
+	l      Logger
+	attrs  []slog.Attr // already qualified with their group
+	groups []string
+}
+
+// NewSlogHandler returns a slog.Handler that forwards records to l. A TransactionIDKey
+// attribute, or a transaction ID on the context passed to slog, tags the line via
+// WithTransactionID. Other attributes become fields when l is a *LeveledLogger and are
+// appended to the message as key=value pairs otherwise.
+func NewSlogHandler(l Logger) slog.Handler {
+	return &slogHandler{l: l}
+}
+
+func (h *slogHandler) Enabled(_ context.Context, lvl slog.Level) bool {
+	if ll, ok := h.l.(*LeveledLogger); ok {
+		return ll.Enabled(fromSlogLevel(lvl))
+	}
+	return true
+}
+
+func (h *slogHandler) Handle(ctx context.Context, r slog.Record) error {
+	var attrs []slog.Attr
+	attrs = append(attrs, h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, qualify(h.groups, a)...)
+		return true
+	})
+
+	l := h.l
+	if id := TransactionIDFromContext(ctx); id != "" {
+		l = WithTransactionID(l, id)
+	}
+	fields := Fields{}
+	var keys []string
+	for _, a := range attrs {
+		if a.Key == TransactionIDKey {
+			l = WithTransactionID(l, a.Value.String())
+			continue
+		}
+		if _, ok := fields[a.Key]; !ok {
+			keys = append(keys, a.Key)
+		}
+		fields[a.Key] = a.Value.Any()
+	}
+
+	msg := r.Message
+	if ll, ok := l.(*LeveledLogger); ok {
+		l = ll.WithFields(fields)
+	} else if len(keys) > 0 {
+		b := new(strings.Builder)
+		b.WriteString(msg)
+		for _, k := range keys {
+			fmt.Fprintf(b, " %v=%v", k, textValue(fields[k]))
+		}
+		msg = b.String()
+	}
+	logAt(l, fromSlogLevel(r.Level), msg)
+	return nil
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	child := *h
+	child.attrs = append([]slog.Attr(nil), h.attrs...)
+	for _, a := range attrs {
+		child.attrs = append(child.attrs, qualify(h.groups, a)...)
+	}
+	return &child
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	child := *h
+	child.groups = append(append([]string(nil), h.groups...), name)
+	return &child
+}
+
+// qualify resolves a and flattens groups into dotted keys, e.g. request.method
+func qualify(groups []string, a slog.Attr) []slog.Attr {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return nil
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		inner := groups
+		if a.Key != "" {
+			inner = append(append([]string(nil), groups...), a.Key)
+		}
+		var res []slog.Attr
+		for _, ga := range a.Value.Group() {
+			res = append(res, qualify(inner, ga)...)
+		}
+		return res
+	}
+	if len(groups) > 0 {
+		a.Key = strings.Join(groups, ".") + "." + a.Key
+	}
+	return []slog.Attr{a}
+}
@@ -0,0 +1,51 @@
+//go:build go1.21
+
+package log_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/serendipity-xyz/common/log"
+	"github.com/serendipity-xyz/common/mocks"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlogLogger(t *testing.T) {
+	out := new(bytes.Buffer)
+	h := slog.NewJSONHandler(out, &slog.HandlerOptions{Level: slog.LevelInfo})
+	l := log.WithTransactionID(log.NewSlogLogger(h), "mockTrxID")
+	l.Debug("dropped")
+	l.Warn("unable to update tokens: %v", "db down")
+	var line map[string]interface{}
+	require.Nil(t, json.Unmarshal(out.Bytes(), &line), "one valid json line")
+	require.Equal(t, "WARN", line["level"], "level")
+	require.Equal(t, "unable to update tokens: db down", line["msg"], "message")
+	require.Equal(t, "mockTrxID", line["trxid"], "transaction id attribute")
+}
+
+func TestSlogHandler(t *testing.T) {
+	rec := mocks.NewRecordingLogger()
+	sl := slog.New(log.NewSlogHandler(rec)).With("service", "worker").WithGroup("req")
+	sl.Info("fetched activity", "id", 42, log.TransactionIDKey, "mockTrxID")
+	ctx := log.ContextWithTransactionID(context.Background(), "ctxTrxID")
+	sl.ErrorContext(ctx, "failed")
+	entries := rec.Entries()
+	require.Len(t, entries, 2, "both lines forwarded")
+	require.Equal(t, mocks.LogEntry{Level: log.InfoLevel, Message: "fetched activity service=worker req.id=42 req.trxid=mockTrxID"}, entries[0], "grouped attributes")
+	require.Equal(t, mocks.LogEntry{Level: log.ErrorLevel, Message: "failed service=worker", TransactionID: "ctxTrxID"}, entries[1], "transaction id from context")
+
+	out := new(bytes.Buffer)
+	ll := log.NewWriterLogger(out, &log.LeveledLoggerParams{MinLevel: log.WarnLevel, JSON: true})
+	sl = slog.New(log.NewSlogHandler(ll))
+	sl.Info("dropped")
+	sl.Warn("kept", log.TransactionIDKey, "mockTrxID", "attempt", 2)
+	var line map[string]interface{}
+	require.Nil(t, json.Unmarshal(out.Bytes(), &line), "one valid json line")
+	require.Equal(t, "warn", line["lvl"], "level")
+	require.Equal(t, "mockTrxID", line["trxid"], "transaction id")
+	require.Equal(t, float64(2), line["attempt"], "attributes become fields")
+}
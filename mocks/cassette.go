@@ -0,0 +1,372 @@
+package mocks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/serendipity-xyz/common/log"
+	"github.com/serendipity-xyz/common/request"
+)
+
+// Interaction is a request and the response it got, as stored in a cassette file
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+type RecordedRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	// Body, if set, must match the request body. Secrets may be stored as [REDACTED].
+	Body Body `json:"body,omitempty"`
+}
+
+type RecordedResponse struct {
+	StatusCode int         `json:"status"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       Body        `json:"body,omitempty"`
+}
+
+// Body is a JSON document written inline, e.g. "body": {"id": 1}, or any other body as a
+// JSON string, e.g. "body": "client_id=1&grant_type=refresh_token"
+type Body []byte
+
+func (b Body) MarshalJSON() ([]byte, error) {
+	if trimmed := bytes.TrimSpace(b); len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed) {
+		return append([]byte(nil), b...), nil
+	}
+	s := new(bytes.Buffer)
+	enc := json.NewEncoder(s)
+	enc.SetEscapeHTML(false) // keep & in form bodies readable
+	if err := enc.Encode(string(b)); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(s.Bytes(), []byte("\n")), nil
+}
+
+func (b *Body) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*b = Body(s)
+		return nil
+	}
+	*b = append(Body(nil), data...)
+	return nil
+}
+
+type cassetteFile struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+type CassetteOpts struct {
+	// Record sends requests through Client instead of replaying them and saves every
+	// interaction to the cassette file when the test ends. Secrets such as access tokens,
+	// client secrets and codes are redacted from urls and bodies.
+	Record bool
+	Client request.HTTPClient
+}
+
+// Cassette is a request.HTTPClient that replays interactions loaded from a JSON file, e.g.
+// testdata/list_activities.json. A request is answered by the first interaction not yet
+// used whose method, path, query and body match it, so a retried request can get a
+// different response each time. Requests nothing matches fail the test.
+type Cassette struct {
+	t      testing.TB
+	path   string
+	record bool
+	client request.HTTPClient
+
+	mu           sync.Mutex
+	interactions []*Interaction
+	used         []bool
+	callCount    int
+}
+
+// NewCassette loads the cassette at path, or prepares to record it if opts.Record is set
+func NewCassette(t testing.TB, path string, opts *CassetteOpts) *Cassette {
+	t.Helper()
+	c := &Cassette{t: t, path: path}
+	if opts != nil {
+		c.record = opts.Record
+		c.client = opts.Client
+	}
+	if c.record {
+		if c.client == nil {
+			c.client = http.DefaultClient
+		}
+		t.Cleanup(c.save)
+		return c
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unable to load cassette: %v", err)
+	}
+	var f cassetteFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		t.Fatalf("unable to parse cassette %v: %v", path, err)
+	}
+	c.interactions = f.Interactions
+	c.used = make([]bool, len(f.Interactions))
+	return c
+}
+
+func (c *Cassette) CallCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.callCount
+}
+
+// Unused returns the interactions no request has matched yet
+func (c *Cassette) Unused() []Interaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var unused []Interaction
+	for i, in := range c.interactions {
+		if !c.used[i] {
+			unused = append(unused, *in)
+		}
+	}
+	return unused
+}
+
+func (c *Cassette) Do(req *http.Request) (*http.Response, error) {
+	body, err := drain(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.callCount++
+	c.mu.Unlock()
+	if c.record {
+		return c.recordInteraction(req, body)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, in := range c.interactions {
+		if !c.used[i] && in.Request.matches(req, body) {
+			c.used[i] = true
+			return in.Response.response(req), nil
+		}
+	}
+	err = unmatchedRequestError{method: req.Method, url: redact(req.URL.String()), body: redact(string(body))}
+	c.t.Errorf("%v\n%v", err, c.describeUnused())
+	return nil, err
+}
+
+func (c *Cassette) describeUnused() string {
+	b := new(strings.Builder)
+	fmt.Fprintf(b, "unused interactions in %v:", c.path)
+	n := 0
+	for i, in := range c.interactions {
+		if !c.used[i] {
+			fmt.Fprintf(b, "\n\t%v %v", in.Request.Method, in.Request.URL)
+			if len(in.Request.Body) > 0 {
+				fmt.Fprintf(b, " (body: %s)", in.Request.Body)
+			}
+			n++
+		}
+	}
+	if n == 0 {
+		b.WriteString(" none")
+	}
+	return b.String()
+}
+
+type unmatchedRequestError struct {
+	method, url, body string
+}
+
+func (e unmatchedRequestError) Error() string {
+	msg := fmt.Sprintf("no cassette interaction matches %v %v", e.method, e.url)
+	if e.body != "" {
+		msg += fmt.Sprintf(" (body: %v)", e.body)
+	}
+	return msg
+}
+
+// drain reads a request or response body and replaces it so it can be read again
+func drain(rc *io.ReadCloser) ([]byte, error) {
+	if *rc == nil || *rc == http.NoBody {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(*rc)
+	(*rc).Close()
+	*rc = ioutil.NopCloser(bytes.NewReader(body))
+	return body, err
+}
+
+func redact(s string) string {
+	return log.Redact(s, log.DefaultRedactors()...)
+}
+
+// matches compares method, host (if recorded), path, query and body (if recorded). Values
+// recorded as [REDACTED] match anything.
+func (rr RecordedRequest) matches(req *http.Request, body []byte) bool {
+	if !strings.EqualFold(rr.Method, req.Method) {
+		return false
+	}
+	u, err := url.Parse(rr.URL)
+	if err != nil {
+		return false
+	}
+	if u.Host != "" && u.Host != req.URL.Host {
+		return false
+	}
+	if u.Path != req.URL.Path {
+		return false
+	}
+	if !sameValues(u.Query(), req.URL.Query()) {
+		return false
+	}
+	if len(rr.Body) == 0 {
+		return true
+	}
+	return sameBody(rr.Body, body)
+}
+
+func sameValues(recorded, actual url.Values) bool {
+	if len(recorded) != len(actual) {
+		return false
+	}
+	for k, want := range recorded {
+		got, ok := actual[k]
+		if !ok || len(got) != len(want) {
+			return false
+		}
+		for i := range want {
+			if want[i] != got[i] && want[i] != log.RedactedValue {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// sameBody compares JSON bodies as documents, form bodies as values and anything else as
+// text, allowing for redaction
+func sameBody(recorded, actual []byte) bool {
+	if json.Valid(recorded) && json.Valid(actual) {
+		var want, got interface{}
+		json.Unmarshal(recorded, &want)
+		json.Unmarshal(actual, &got)
+		return jsonEqual(want, got)
+	}
+	want, err1 := url.ParseQuery(string(recorded))
+	got, err2 := url.ParseQuery(string(actual))
+	if err1 == nil && err2 == nil && strings.Contains(string(recorded), "=") {
+		return sameValues(want, got)
+	}
+	return string(recorded) == string(actual) || string(recorded) == redact(string(actual))
+}
+
+func jsonEqual(want, got interface{}) bool {
+	if s, ok := want.(string); ok && s == log.RedactedValue {
+		return true
+	}
+	switch w := want.(type) {
+	case map[string]interface{}:
+		g, ok := got.(map[string]interface{})
+		if !ok || len(g) != len(w) {
+			return false
+		}
+		for k, v := range w {
+			if gv, ok := g[k]; !ok || !jsonEqual(v, gv) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		g, ok := got.([]interface{})
+		if !ok || len(g) != len(w) {
+			return false
+		}
+		for i := range w {
+			if !jsonEqual(w[i], g[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(want, got)
+}
+
+func (rr RecordedResponse) response(req *http.Request) *http.Response {
+	header := rr.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	if header.Get("Content-Type") == "" && json.Valid(rr.Body) {
+		header.Set("Content-Type", "application/json")
+	}
+	status := rr.StatusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return &http.Response{
+		Status:        http.StatusText(status),
+		StatusCode:    status,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(rr.Body)),
+		ContentLength: int64(len(rr.Body)),
+		Request:       req,
+	}
+}
+
+func (c *Cassette) recordInteraction(req *http.Request, body []byte) (*http.Response, error) {
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return resp, err
+	}
+	respBody, err := drain(&resp.Body)
+	if err != nil {
+		return resp, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.interactions = append(c.interactions, &Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    redact(req.URL.String()),
+			Body:   Body(redact(string(body))),
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header.Clone(),
+			Body:       Body(redact(string(respBody))),
+		},
+	})
+	c.used = append(c.used, true)
+	return resp, nil
+}
+
+func (c *Cassette) save() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data := new(bytes.Buffer)
+	enc := json.NewEncoder(data)
+	enc.SetEscapeHTML(false) // keep & in urls and form bodies readable
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(cassetteFile{Interactions: c.interactions}); err != nil {
+		c.t.Errorf("unable to encode cassette: %v", err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		c.t.Errorf("unable to save cassette: %v", err)
+		return
+	}
+	if err := ioutil.WriteFile(c.path, data.Bytes(), 0644); err != nil {
+		c.t.Errorf("unable to save cassette: %v", err)
+	}
+}
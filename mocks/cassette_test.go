@@ -0,0 +1,105 @@
+package mocks_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/serendipity-xyz/common/mocks"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTB records the failures and cleanups a mock registers instead of acting on them
+type fakeTB struct {
+	testing.TB
+	errors   []string
+	cleanups []func()
+}
+
+func (tb *fakeTB) Helper() {}
+
+func (tb *fakeTB) Errorf(format string, args ...interface{}) {
+	tb.errors = append(tb.errors, fmt.Sprintf(format, args...))
+}
+
+func (tb *fakeTB) Fatalf(format string, args ...interface{}) {
+	panic(fmt.Sprintf(format, args...))
+}
+
+func (tb *fakeTB) Cleanup(f func()) { tb.cleanups = append(tb.cleanups, f) }
+
+// finish runs the cleanups the way the testing package does at the end of a test
+func (tb *fakeTB) finish() {
+	for i := len(tb.cleanups) - 1; i >= 0; i-- {
+		tb.cleanups[i]()
+	}
+}
+
+type clientFunc func(req *http.Request) (*http.Response, error)
+
+func (f clientFunc) Do(req *http.Request) (*http.Response, error) { return f(req) }
+
+// tokenServer stands in for Strava's token endpoint and echoes secrets back
+var tokenServer = clientFunc(func(req *http.Request) (*http.Response, error) {
+	body := `{"access_token": "secretAccessToken", "refresh_token": "secretRefreshToken", "expires_at": 123}`
+	return &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+	}, nil
+})
+
+func tokenRequest(t *testing.T) *http.Request {
+	req, err := http.NewRequest(http.MethodPost, "https://www.strava.com/api/v3/oauth/token?code=secretCode",
+		strings.NewReader("client_id=1&client_secret=secretClientSecret&grant_type=authorization_code"))
+	require.Nil(t, err, "no error building request")
+	req.Header.Set("Authorization", "Bearer secretBearer")
+	return req
+}
+
+func TestCassetteRecordAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "testdata", "generate_tokens.json")
+	tb := &fakeTB{}
+	recorder := mocks.NewCassette(tb, path, &mocks.CassetteOpts{Record: true, Client: tokenServer})
+	resp, err := recorder.Do(tokenRequest(t))
+	require.Nil(t, err, "no error recording")
+	body, err := ioutil.ReadAll(resp.Body)
+	require.Nil(t, err, "no error reading response")
+	require.Contains(t, string(body), "secretAccessToken", "caller gets the real response")
+
+	tb.finish()
+	require.Empty(t, tb.errors, "no errors saving")
+	data, err := ioutil.ReadFile(path)
+	require.Nil(t, err, "cassette saved on cleanup")
+	for _, secret := range []string{"secretAccessToken", "secretRefreshToken", "secretCode", "secretClientSecret", "secretBearer"} {
+		require.NotContains(t, string(data), secret, "secret redacted from cassette")
+	}
+	require.Contains(t, string(data), "client_id=1&client_secret=[REDACTED]", "form body kept readable")
+
+	replay := mocks.NewCassette(t, path, nil)
+	resp, err = replay.Do(tokenRequest(t))
+	require.Nil(t, err, "recorded interaction matches")
+	require.Equal(t, 200, resp.StatusCode, "status")
+	body, err = ioutil.ReadAll(resp.Body)
+	require.Nil(t, err, "no error reading response")
+	require.JSONEq(t, `{"access_token": "[REDACTED]", "refresh_token": "[REDACTED]", "expires_at": 123}`, string(body), "recorded body")
+	require.Empty(t, replay.Unused(), "interaction used")
+	require.Equal(t, 1, replay.CallCount(), "call count")
+}
+
+func TestCassetteUnmatchedRequest(t *testing.T) {
+	tb := &fakeTB{}
+	c := mocks.NewCassette(tb, "../strava/testdata/get_activity.json", nil)
+	req, err := http.NewRequest(http.MethodGet, "https://www.strava.com/api/v3/athlete?access_token=secretAccessToken", nil)
+	require.Nil(t, err, "no error building request")
+	_, err = c.Do(req)
+	require.NotNil(t, err, "unmatched request fails")
+	require.Len(t, tb.errors, 1, "test is failed")
+	require.Contains(t, tb.errors[0], "no cassette interaction matches GET https://www.strava.com/api/v3/athlete", "request is reported")
+	require.Contains(t, tb.errors[0], "unused interactions in ../strava/testdata/get_activity.json", "unused interactions are listed")
+	require.NotContains(t, tb.errors[0], "secretAccessToken", "secrets redacted from the report")
+	require.Len(t, c.Unused(), 1, "nothing used")
+}
@@ -0,0 +1,153 @@
+package mocks
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/serendipity-xyz/common/log"
+)
+
+// LogEntry is a line captured by a RecordingLogger
+type LogEntry struct {
+	Level         log.Level
+	Message       string // the formatted message
+	TransactionID string
+}
+
+func (e LogEntry) String() string {
+	if e.TransactionID != "" {
+		return fmt.Sprintf("[trxid: %v][lvl: %v] %v", e.TransactionID, e.Level, e.Message)
+	}
+	return fmt.Sprintf("[lvl: %v] %v", e.Level, e.Message)
+}
+
+type logStore struct {
+	mu      sync.Mutex
+	entries []LogEntry
+}
+
+// RecordingLogger is a log.Logger that keeps every line in memory so tests can assert on
+// what was logged. Child loggers created with WithTransactionID record into the same store.
+type RecordingLogger struct {
+	TransactionID string
+	store         *logStore
+}
+
+func NewRecordingLogger() *RecordingLogger {
+	return &RecordingLogger{store: &logStore{}}
+}
+
+func (rl *RecordingLogger) record(lvl log.Level, s string, a ...interface{}) {
+	rl.store.mu.Lock()
+	defer rl.store.mu.Unlock()
+	rl.store.entries = append(rl.store.entries, LogEntry{
+		Level:         lvl,
+		Message:       fmt.Sprintf(s, a...),
+		TransactionID: rl.TransactionID,
+	})
+}
+
+func (rl *RecordingLogger) Debug(s string, a ...interface{}) { rl.record(log.DebugLevel, s, a...) }
+
+func (rl *RecordingLogger) Info(s string, a ...interface{}) { rl.record(log.InfoLevel, s, a...) }
+
+func (rl *RecordingLogger) Warn(s string, a ...interface{}) { rl.record(log.WarnLevel, s, a...) }
+
+func (rl *RecordingLogger) Error(s string, a ...interface{}) { rl.record(log.ErrorLevel, s, a...) }
+
+func (rl *RecordingLogger) WithTransactionID(id string) log.Logger {
+	return &RecordingLogger{TransactionID: id, store: rl.store}
+}
+
+// Entries returns a copy of everything logged so far
+func (rl *RecordingLogger) Entries() []LogEntry {
+	rl.store.mu.Lock()
+	defer rl.store.mu.Unlock()
+	return append([]LogEntry(nil), rl.store.entries...)
+}
+
+// EntriesAt returns everything logged at the given level
+func (rl *RecordingLogger) EntriesAt(lvl log.Level) []LogEntry {
+	var res []LogEntry
+	for _, e := range rl.Entries() {
+		if e.Level == lvl {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+// Reset discards all recorded entries
+func (rl *RecordingLogger) Reset() {
+	rl.store.mu.Lock()
+	defer rl.store.mu.Unlock()
+	rl.store.entries = nil
+}
+
+// Contains reports whether a line at the given level contains substr
+func (rl *RecordingLogger) Contains(lvl log.Level, substr string) bool {
+	for _, e := range rl.EntriesAt(lvl) {
+		if strings.Contains(e.Message, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExpectLogged fails the test if no line at the given level contains substr
+func (rl *RecordingLogger) ExpectLogged(t testing.TB, lvl log.Level, substr string) {
+	t.Helper()
+	if !rl.Contains(lvl, substr) {
+		t.Errorf("expected a %v log containing %q, got:\n%v", lvl, substr, rl.dump())
+	}
+}
+
+// ExpectNotLogged fails the test if any line at the given level contains substr
+func (rl *RecordingLogger) ExpectNotLogged(t testing.TB, lvl log.Level, substr string) {
+	t.Helper()
+	if rl.Contains(lvl, substr) {
+		t.Errorf("expected no %v log containing %q, got:\n%v", lvl, substr, rl.dump())
+	}
+}
+
+func (rl *RecordingLogger) ExpectDebug(t testing.TB, substr string) {
+	t.Helper()
+	rl.ExpectLogged(t, log.DebugLevel, substr)
+}
+
+func (rl *RecordingLogger) ExpectInfo(t testing.TB, substr string) {
+	t.Helper()
+	rl.ExpectLogged(t, log.InfoLevel, substr)
+}
+
+func (rl *RecordingLogger) ExpectWarn(t testing.TB, substr string) {
+	t.Helper()
+	rl.ExpectLogged(t, log.WarnLevel, substr)
+}
+
+func (rl *RecordingLogger) ExpectError(t testing.TB, substr string) {
+	t.Helper()
+	rl.ExpectLogged(t, log.ErrorLevel, substr)
+}
+
+// ExpectNoErrors fails the test if anything was logged at error level
+func (rl *RecordingLogger) ExpectNoErrors(t testing.TB) {
+	t.Helper()
+	if errs := rl.EntriesAt(log.ErrorLevel); len(errs) > 0 {
+		t.Errorf("expected no error logs, got:\n%v", rl.dump())
+	}
+}
+
+func (rl *RecordingLogger) dump() string {
+	entries := rl.Entries()
+	if len(entries) == 0 {
+		return "\t(nothing logged)"
+	}
+	lines := make([]string, len(entries))
+	for i, e := range entries {
+		lines[i] = "\t" + e.String()
+	}
+	return strings.Join(lines, "\n")
+}
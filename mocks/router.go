@@ -0,0 +1,286 @@
+package mocks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Router is a request.HTTPClient that answers requests by route instead of call order, so
+// tests keep passing when the order of calls changes. Routes are matched by method, path
+// pattern and any header, query or body matchers, in the order they were added. Requests
+// no route matches fail the test, and routes that were not called as expected are reported
+// when the test ends.
+//
+//	router := mocks.NewRouter(t)
+//	router.On("POST", "/api/v3/oauth/token").RespondJSON(200, tokens)
+//	router.On("GET", "/api/v3/activities/{id}").
+//		WithHeader("Authorization", "Bearer newAccessToken").
+//		Respond(500, "").
+//		RespondJSON(200, activity)
+type Router struct {
+	t         testing.TB
+	mu        sync.Mutex
+	routes    []*Route
+	callCount int
+}
+
+func NewRouter(t testing.TB) *Router {
+	r := &Router{t: t}
+	t.Cleanup(r.verify)
+	return r
+}
+
+// On adds a route. Path segments written as {name} or * match any single segment.
+func (r *Router) On(method, pattern string) *Route {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	route := &Route{mu: &r.mu, method: strings.ToUpper(method), pattern: pattern, min: 1, max: -1}
+	r.routes = append(r.routes, route)
+	return route
+}
+
+func (r *Router) CallCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.callCount
+}
+
+func (r *Router) Do(req *http.Request) (*http.Response, error) {
+	body, err := drain(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.callCount++
+	var matched *Route
+	for _, route := range r.routes {
+		if !route.matches(req, body) {
+			continue
+		}
+		if route.max < 0 || len(route.calls) < route.max {
+			matched = route
+			break
+		}
+		if matched == nil {
+			matched = route // every match is used up, the extra call is reported at the end
+		}
+	}
+	if matched == nil {
+		err := fmt.Errorf("no route matches %v %v", req.Method, redact(req.URL.String()))
+		r.t.Errorf("%v\n%v", err, r.describe())
+		return nil, err
+	}
+	return matched.respond(req)
+}
+
+func (r *Router) describe() string {
+	b := new(strings.Builder)
+	b.WriteString("routes:")
+	for _, route := range r.routes {
+		fmt.Fprintf(b, "\n\t%v", route)
+	}
+	if len(r.routes) == 0 {
+		b.WriteString(" none")
+	}
+	return b.String()
+}
+
+func (r *Router) verify() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, route := range r.routes {
+		n := len(route.calls)
+		switch {
+		case route.max >= 0 && route.min == route.max && n != route.max:
+			r.t.Errorf("route %v called %v time(s), expected %v", route, n, route.max)
+		case n < route.min:
+			r.t.Errorf("route %v called %v time(s), expected at least %v", route, n, route.min)
+		case route.max >= 0 && n > route.max:
+			r.t.Errorf("route %v called %v time(s), expected at most %v", route, n, route.max)
+		}
+	}
+}
+
+// Route answers the requests it matches with its responses in turn, repeating the last one
+type Route struct {
+	mu        *sync.Mutex // the router's
+	method    string
+	pattern   string
+	matchers  []matcher
+	responses []func(req *http.Request) (*http.Response, error)
+	min, max  int // expected number of calls, max -1 means no limit
+	calls     []*http.Request
+}
+
+type matcher struct {
+	desc  string
+	match func(req *http.Request, body []byte) bool
+}
+
+func (rt *Route) String() string {
+	s := rt.method + " " + rt.pattern
+	for _, m := range rt.matchers {
+		s += " " + m.desc
+	}
+	return s
+}
+
+// Calls returns the requests the route has answered. Their bodies can be read again.
+func (rt *Route) Calls() []*http.Request {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return append([]*http.Request(nil), rt.calls...)
+}
+
+func (rt *Route) CallCount() int {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return len(rt.calls)
+}
+
+func (rt *Route) matches(req *http.Request, body []byte) bool {
+	if rt.method != req.Method || !matchPath(rt.pattern, req.URL.Path) {
+		return false
+	}
+	for _, m := range rt.matchers {
+		if !m.match(req, body) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchPath(pattern, path string) bool {
+	want := strings.Split(strings.Trim(pattern, "/"), "/")
+	got := strings.Split(strings.Trim(path, "/"), "/")
+	if len(want) != len(got) {
+		return false
+	}
+	for i, w := range want {
+		wildcard := w == "*" || (strings.HasPrefix(w, "{") && strings.HasSuffix(w, "}"))
+		if wildcard && got[i] != "" {
+			continue
+		}
+		if w != got[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Match adds a custom matcher, described by desc when reporting
+func (rt *Route) Match(desc string, match func(req *http.Request, body []byte) bool) *Route {
+	rt.matchers = append(rt.matchers, matcher{desc: desc, match: match})
+	return rt
+}
+
+// WithHeader only matches requests with the header set to value
+func (rt *Route) WithHeader(key, value string) *Route {
+	return rt.Match(fmt.Sprintf("[header %v: %v]", key, redact(value)), func(req *http.Request, body []byte) bool {
+		return req.Header.Get(key) == value
+	})
+}
+
+// WithQuery only matches requests with the query parameter set to value
+func (rt *Route) WithQuery(key, value string) *Route {
+	return rt.Match(fmt.Sprintf("[query %v=%v]", key, value), func(req *http.Request, body []byte) bool {
+		return req.URL.Query().Get(key) == value
+	})
+}
+
+// WithFormValue only matches requests with a form encoded body holding key=value
+func (rt *Route) WithFormValue(key, value string) *Route {
+	return rt.Match(fmt.Sprintf("[form %v=%v]", key, value), func(req *http.Request, body []byte) bool {
+		values, err := url.ParseQuery(string(body))
+		return err == nil && values.Get(key) == value
+	})
+}
+
+// WithBody only matches requests whose body is exactly body. JSON bodies are compared as
+// documents, so whitespace and key order don't matter.
+func (rt *Route) WithBody(body string) *Route {
+	return rt.Match(fmt.Sprintf("[body %v]", body), func(req *http.Request, actual []byte) bool {
+		if json.Valid([]byte(body)) && json.Valid(actual) {
+			return sameBody([]byte(body), actual)
+		}
+		return string(actual) == body
+	})
+}
+
+// WithBodyContaining only matches requests whose body contains substr
+func (rt *Route) WithBodyContaining(substr string) *Route {
+	return rt.Match(fmt.Sprintf("[body containing %v]", substr), func(req *http.Request, body []byte) bool {
+		return strings.Contains(string(body), substr)
+	})
+}
+
+// Respond adds a response with the given status and body to the route's sequence
+func (rt *Route) Respond(status int, body string, header ...http.Header) *Route {
+	h := http.Header{}
+	for _, extra := range header {
+		for k, v := range extra {
+			h[k] = append(h[k], v...)
+		}
+	}
+	rt.responses = append(rt.responses, func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			Status:        http.StatusText(status),
+			StatusCode:    status,
+			Header:        h.Clone(),
+			Body:          ioutil.NopCloser(strings.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	})
+	return rt
+}
+
+// RespondJSON adds a response with v encoded as JSON to the route's sequence
+func (rt *Route) RespondJSON(status int, v interface{}) *Route {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("unable to encode mock response for %v: %v", rt, err))
+	}
+	return rt.Respond(status, string(b), http.Header{"Content-Type": []string{"application/json"}})
+}
+
+// RespondError makes the call fail with err, e.g. to simulate a timeout
+func (rt *Route) RespondError(err error) *Route {
+	rt.responses = append(rt.responses, func(req *http.Request) (*http.Response, error) {
+		return nil, err
+	})
+	return rt
+}
+
+// Times expects the route to be called exactly n times. Without it a route is expected to
+// be called at least once.
+func (rt *Route) Times(n int) *Route {
+	rt.min, rt.max = n, n
+	return rt
+}
+
+// Optional lets the route go uncalled
+func (rt *Route) Optional() *Route {
+	rt.min = 0
+	return rt
+}
+
+func (rt *Route) respond(req *http.Request) (*http.Response, error) {
+	rt.calls = append(rt.calls, req)
+	if len(rt.responses) == 0 {
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: ioutil.NopCloser(bytes.NewReader(nil)), Request: req}, nil
+	}
+	i := len(rt.calls) - 1
+	if i >= len(rt.responses) {
+		i = len(rt.responses) - 1
+	}
+	return rt.responses[i](req)
+}
@@ -0,0 +1,86 @@
+package mocks_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/serendipity-xyz/common/mocks"
+	"github.com/stretchr/testify/require"
+)
+
+func get(t *testing.T, r *mocks.Router, url string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.Nil(t, err, "no error building request")
+	return r.Do(req)
+}
+
+func TestRouterTimes(t *testing.T) {
+	tb := &fakeTB{}
+	r := mocks.NewRouter(tb)
+	under := r.On("GET", "/under").Times(2)
+	over := r.On("GET", "/over").Respond(200, "first").Times(1)
+	exact := r.On("GET", "/exact").Times(1)
+	_, err := get(t, r, "http://mock/under")
+	require.Nil(t, err, "no error")
+	_, err = get(t, r, "http://mock/exact")
+	require.Nil(t, err, "no error")
+	for i := 0; i < 2; i++ {
+		resp, err := get(t, r, "http://mock/over")
+		require.Nil(t, err, "extra calls are still answered")
+		body, _ := ioutil.ReadAll(resp.Body)
+		require.Equal(t, "first", string(body), "last response repeated")
+	}
+	require.Equal(t, 1, under.CallCount(), "under call count")
+	require.Equal(t, 2, over.CallCount(), "over call count")
+	require.Equal(t, 1, exact.CallCount(), "exact call count")
+	require.Empty(t, tb.errors, "nothing reported before the test ends")
+
+	tb.finish()
+	require.Equal(t, []string{
+		"route GET /under called 1 time(s), expected 2",
+		"route GET /over called 2 time(s), expected 1",
+	}, tb.errors, "wrong call counts reported")
+}
+
+func TestRouterDefaultExpectations(t *testing.T) {
+	tb := &fakeTB{}
+	r := mocks.NewRouter(tb)
+	r.On("GET", "/required").WithHeader("Authorization", "Bearer secretToken")
+	r.On("GET", "/optional").Optional()
+	r.On("GET", "/many")
+	for i := 0; i < 3; i++ {
+		_, err := get(t, r, "http://mock/many")
+		require.Nil(t, err, "no error")
+	}
+	tb.finish()
+	require.Equal(t, []string{
+		"route GET /required [header Authorization: Bearer [REDACTED]] called 0 time(s), expected at least 1",
+	}, tb.errors, "only the uncalled required route is reported")
+}
+
+func TestRouterNoRoute(t *testing.T) {
+	tb := &fakeTB{}
+	r := mocks.NewRouter(tb)
+	r.On("POST", "/api/v3/oauth/token").Optional()
+	_, err := get(t, r, "http://mock/api/v3/oauth/token?access_token=secretToken")
+	require.NotNil(t, err, "unmatched request fails")
+	require.Equal(t, "no route matches GET http://mock/api/v3/oauth/token?access_token=[REDACTED]", err.Error(), "error")
+	require.Len(t, tb.errors, 1, "test is failed")
+	require.Contains(t, tb.errors[0], "routes:\n\tPOST /api/v3/oauth/token", "routes are listed")
+	require.Equal(t, 1, r.CallCount(), "call count")
+}
+
+func TestRouterPathWildcard(t *testing.T) {
+	tb := &fakeTB{}
+	r := mocks.NewRouter(tb)
+	streams := r.On("GET", "/api/v3/activities/{id}/streams").Optional()
+	_, err := get(t, r, "http://mock/api/v3/activities/123/streams")
+	require.Nil(t, err, "wildcard matches a segment")
+	_, err = get(t, r, "http://mock/api/v3/activities//streams")
+	require.NotNil(t, err, "wildcard does not match an empty segment")
+	_, err = get(t, r, "http://mock/api/v3/activities/123/456/streams")
+	require.NotNil(t, err, "wildcard matches a single segment")
+	require.Equal(t, 1, streams.CallCount(), "call count")
+	require.Len(t, tb.errors, 2, "unmatched requests reported")
+}
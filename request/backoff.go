@@ -0,0 +1,106 @@
+package request
+
+import (
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Backoff decides how long to wait before a retry. attempt is 1 for the first retry and
+// prev is the previous wait, 0 before the first retry.
+type Backoff interface {
+	Next(attempt int, prev time.Duration) time.Duration
+}
+
+// ConstantBackoff always waits Interval
+type ConstantBackoff struct {
+	Interval time.Duration
+}
+
+func (b ConstantBackoff) Next(attempt int, prev time.Duration) time.Duration {
+	return b.Interval
+}
+
+// ExponentialBackoff waits Initial, then Initial*Multiplier, Initial*Multiplier^2... up to
+// Max. Jitter randomises each wait by up to that fraction in either direction, e.g. 0.2
+// gives 80% to 120% of the computed wait.
+type ExponentialBackoff struct {
+	Initial    time.Duration
+	Max        time.Duration // 0 means no cap
+	Multiplier float64       // defaults to 2
+	Jitter     float64       // between 0 and 1
+}
+
+func (b ExponentialBackoff) Next(attempt int, prev time.Duration) time.Duration {
+	multiplier := b.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+	if attempt < 1 {
+		attempt = 1
+	}
+	d := float64(b.Initial) * math.Pow(multiplier, float64(attempt-1))
+	if b.Jitter > 0 {
+		jitter := math.Min(b.Jitter, 1)
+		d = d * (1 - jitter + 2*jitter*rand.Float64())
+	}
+	if b.Max > 0 && d > float64(b.Max) {
+		return b.Max
+	}
+	if d > math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(d)
+}
+
+// DecorrelatedJitterBackoff picks a random wait between Base and three times the previous
+// wait, capped at Max. Spreads out retries from many clients better than plain exponential
+// backoff. See https://aws.amazon.com/blogs/architecture/exponential-backoff-and-jitter/
+type DecorrelatedJitterBackoff struct {
+	Base time.Duration
+	Max  time.Duration // 0 means no cap
+}
+
+func (b DecorrelatedJitterBackoff) Next(attempt int, prev time.Duration) time.Duration {
+	if prev < b.Base {
+		prev = b.Base
+	}
+	upper := 3 * prev
+	d := b.Base
+	if upper > b.Base {
+		d += time.Duration(rand.Int63n(int64(upper - b.Base)))
+	}
+	if b.Max > 0 && d > b.Max {
+		return b.Max
+	}
+	return d
+}
+
+// retryAfter returns the wait requested by a Retry-After header on a 429 or 503 response.
+// The header may hold a number of seconds or an http date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil || (resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable) {
+		return 0, false
+	}
+	v := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			secs = 0
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
@@ -0,0 +1,111 @@
+package request_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/serendipity-xyz/common/mocks"
+	"github.com/serendipity-xyz/common/request"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	b := request.ExponentialBackoff{Initial: 100 * time.Millisecond, Max: time.Second}
+	var waits []time.Duration
+	for attempt := 1; attempt <= 6; attempt++ {
+		waits = append(waits, b.Next(attempt, 0))
+	}
+	require.Equal(t, []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	}, waits, "doubles up to the cap")
+
+	jittered := request.ExponentialBackoff{Initial: 100 * time.Millisecond, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		d := jittered.Next(1, 0)
+		require.GreaterOrEqual(t, d, 50*time.Millisecond, "lower jitter bound")
+		require.LessOrEqual(t, d, 150*time.Millisecond, "upper jitter bound")
+	}
+}
+
+func TestDecorrelatedJitterBackoff(t *testing.T) {
+	b := request.DecorrelatedJitterBackoff{Base: 10 * time.Millisecond, Max: 100 * time.Millisecond}
+	prev := time.Duration(0)
+	for attempt := 1; attempt <= 50; attempt++ {
+		d := b.Next(attempt, prev)
+		require.GreaterOrEqual(t, d, 10*time.Millisecond, "at least base")
+		require.LessOrEqual(t, d, 100*time.Millisecond, "at most max")
+		if prev > 0 {
+			require.LessOrEqual(t, d, 3*prev, "at most three times the previous wait")
+		}
+		prev = d
+	}
+}
+
+func TestHonorsRetryAfter(t *testing.T) {
+	httpClient := mocks.NewRequestMock(&mocks.NewRequestMockOpts{
+		Responses: []*http.Response{
+			{
+				StatusCode: 429,
+				Header:     http.Header{"Retry-After": []string{"0"}},
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{}`))),
+			},
+			{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"hello": "test"}`))),
+			},
+		},
+	})
+	var res map[string]interface{}
+	start := time.Now()
+	_, err := request.DefaultR(httpClient).SetResult(&res).Get("mockURL/v1/path")
+	require.Nil(t, err, "no error")
+	require.Less(t, time.Since(start), time.Second, "retry-after replaces the 2s backoff")
+	require.Equal(t, 2, httpClient.CallCount(), "call count")
+	require.Equal(t, map[string]interface{}{"hello": "test"}, res, "expected output to be equal")
+}
+
+func TestRetryAfterBeyondMaxElapsedTime(t *testing.T) {
+	httpClient := mocks.NewRequestMock(&mocks.NewRequestMockOpts{
+		Responses: []*http.Response{
+			{
+				StatusCode: 503,
+				Header:     http.Header{"Retry-After": []string{"120"}},
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{}`))),
+			},
+		},
+	})
+	var res interface{}
+	start := time.Now()
+	_, err := request.DefaultR(httpClient).SetResult(&res).SetMaxElapsedTime(time.Second).Get("mockURL/v1/path")
+	require.NotNil(t, err, "expected err")
+	require.Equal(t, "max retries exhausted: bad status code: 503 (GET mockURL/v1/path): {}", err.Error(), "err check")
+	require.Less(t, time.Since(start), time.Second, "gave up without waiting")
+	require.Equal(t, 1, httpClient.CallCount(), "call count")
+}
+
+func TestCustomBackoff(t *testing.T) {
+	httpClient := mocks.NewRequestMock(&mocks.NewRequestMockOpts{
+		Responses: []*http.Response{
+			{StatusCode: 500, Body: ioutil.NopCloser(bytes.NewReader([]byte(`{}`)))},
+			{StatusCode: 500, Body: ioutil.NopCloser(bytes.NewReader([]byte(`{}`)))},
+			{StatusCode: 500, Body: ioutil.NopCloser(bytes.NewReader([]byte(`{}`)))},
+			{StatusCode: 200, Body: ioutil.NopCloser(bytes.NewReader([]byte(`{}`)))},
+		},
+	})
+	var res interface{}
+	_, err := request.DefaultR(httpClient).
+		SetResult(&res).
+		SetRetries(3).
+		SetBackoff(request.ExponentialBackoff{Initial: time.Millisecond}).
+		Get("mockURL/v1/path")
+	require.Nil(t, err, "no error")
+	require.Equal(t, 4, httpClient.CallCount(), "call count")
+}
@@ -0,0 +1,228 @@
+package request
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/serendipity-xyz/common/log"
+)
+
+// ErrCircuitOpen is returned without sending the request while a CircuitBreaker is open.
+// Requests failing with it are never retried.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type BreakerState int
+
+const (
+	StateClosed   BreakerState = iota // requests flow, failures are counted
+	StateOpen                         // requests fail fast with ErrCircuitOpen
+	StateHalfOpen                     // a few trial requests decide whether to close again
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+type CircuitBreakerParams struct {
+	Name string // identifies the dependency in log lines, e.g. "strava"
+	// FailureThreshold is how many consecutive failures open the circuit, defaults to 5
+	FailureThreshold int
+	// OpenTimeout is how long the circuit stays open before letting trial requests
+	// through, defaults to 30 seconds
+	OpenTimeout time.Duration
+	// HalfOpenMaxRequests is how many trial requests may be in flight at once while half
+	// open, defaults to 1
+	HalfOpenMaxRequests int
+	// SuccessThreshold is how many trial requests must succeed to close the circuit,
+	// defaults to 1. Any failed trial opens it again.
+	SuccessThreshold int
+	// IsFailure decides what counts as a failure, defaults to DefaultFailurePolicy
+	IsFailure func(resp *http.Response, err error) bool
+	// OnStateChange is called after every transition
+	OnStateChange func(name string, from, to BreakerState)
+	// Logger, if set, logs transitions: opening at warn level, anything else at info level
+	Logger log.Logger
+}
+
+// DefaultFailurePolicy counts transport errors other than cancellations, 429s and 5xxs as
+// failures
+func DefaultFailurePolicy(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled)
+	}
+	if resp == nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// CircuitBreaker wraps an HTTPClient and stops calling it once it keeps failing, so an
+// outage downstream fails fast instead of piling up retries and latency. Share one per
+// dependency.
+type CircuitBreaker struct {
+	client              HTTPClient
+	name                string
+	failureThreshold    int
+	openTimeout         time.Duration
+	halfOpenMaxRequests int
+	successThreshold    int
+	isFailure           func(resp *http.Response, err error) bool
+	onStateChange       func(name string, from, to BreakerState)
+	l                   log.Logger
+
+	mu         sync.Mutex
+	state      BreakerState
+	generation uint64 // bumped on every transition so stale results are ignored
+	failures   int
+	successes  int
+	inFlight   int
+	openedAt   time.Time
+}
+
+func NewCircuitBreaker(client HTTPClient, params *CircuitBreakerParams) *CircuitBreaker {
+	cb := &CircuitBreaker{
+		client:              client,
+		name:                params.Name,
+		failureThreshold:    params.FailureThreshold,
+		openTimeout:         params.OpenTimeout,
+		halfOpenMaxRequests: params.HalfOpenMaxRequests,
+		successThreshold:    params.SuccessThreshold,
+		isFailure:           params.IsFailure,
+		onStateChange:       params.OnStateChange,
+		l:                   params.Logger,
+	}
+	if cb.failureThreshold <= 0 {
+		cb.failureThreshold = 5
+	}
+	if cb.openTimeout <= 0 {
+		cb.openTimeout = 30 * time.Second
+	}
+	if cb.halfOpenMaxRequests <= 0 {
+		cb.halfOpenMaxRequests = 1
+	}
+	if cb.successThreshold <= 0 {
+		cb.successThreshold = 1
+	}
+	if cb.isFailure == nil {
+		cb.isFailure = DefaultFailurePolicy
+	}
+	return cb
+}
+
+type transition struct {
+	from, to BreakerState
+}
+
+// State returns the current state of the circuit
+func (cb *CircuitBreaker) State() BreakerState {
+	cb.mu.Lock()
+	t := cb.refresh(time.Now())
+	state := cb.state
+	cb.mu.Unlock()
+	cb.notify(t)
+	return state
+}
+
+func (cb *CircuitBreaker) Do(req *http.Request) (*http.Response, error) {
+	gen, err := cb.before()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := cb.client.Do(req)
+	cb.after(gen, cb.isFailure(resp, err))
+	return resp, err
+}
+
+func (cb *CircuitBreaker) before() (uint64, error) {
+	cb.mu.Lock()
+	t := cb.refresh(time.Now())
+	var err error
+	switch cb.state {
+	case StateOpen:
+		err = ErrCircuitOpen
+	case StateHalfOpen:
+		if cb.inFlight >= cb.halfOpenMaxRequests {
+			err = ErrCircuitOpen
+		} else {
+			cb.inFlight++
+		}
+	}
+	gen := cb.generation
+	cb.mu.Unlock()
+	cb.notify(t)
+	return gen, err
+}
+
+func (cb *CircuitBreaker) after(gen uint64, failed bool) {
+	cb.mu.Lock()
+	t := cb.refresh(time.Now())
+	if gen == cb.generation {
+		switch cb.state {
+		case StateClosed:
+			if !failed {
+				cb.failures = 0
+			} else if cb.failures++; cb.failures >= cb.failureThreshold {
+				t = cb.setState(StateOpen, time.Now())
+			}
+		case StateHalfOpen:
+			cb.inFlight--
+			if failed {
+				t = cb.setState(StateOpen, time.Now())
+			} else if cb.successes++; cb.successes >= cb.successThreshold {
+				t = cb.setState(StateClosed, time.Now())
+			}
+		}
+	}
+	cb.mu.Unlock()
+	cb.notify(t)
+}
+
+// refresh moves an open circuit to half open once the open timeout has passed. Must be
+// called with mu held.
+func (cb *CircuitBreaker) refresh(now time.Time) *transition {
+	if cb.state == StateOpen && now.Sub(cb.openedAt) >= cb.openTimeout {
+		return cb.setState(StateHalfOpen, now)
+	}
+	return nil
+}
+
+// setState must be called with mu held. Hooks are run by notify once it is released.
+func (cb *CircuitBreaker) setState(to BreakerState, now time.Time) *transition {
+	t := &transition{from: cb.state, to: to}
+	cb.state = to
+	cb.generation++
+	cb.failures = 0
+	cb.successes = 0
+	cb.inFlight = 0
+	if to == StateOpen {
+		cb.openedAt = now
+	}
+	return t
+}
+
+func (cb *CircuitBreaker) notify(t *transition) {
+	if t == nil {
+		return
+	}
+	if cb.l != nil {
+		if t.to == StateOpen {
+			cb.l.Warn("circuit breaker %v opened (was %v), failing fast for %v", cb.name, t.from, cb.openTimeout)
+		} else {
+			cb.l.Info("circuit breaker %v changed from %v to %v", cb.name, t.from, t.to)
+		}
+	}
+	if cb.onStateChange != nil {
+		cb.onStateChange(cb.name, t.from, t.to)
+	}
+}
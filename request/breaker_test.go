@@ -0,0 +1,98 @@
+package request_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/serendipity-xyz/common/mocks"
+	"github.com/serendipity-xyz/common/request"
+	"github.com/stretchr/testify/require"
+)
+
+// switchClient responds with whatever status is currently set
+type switchClient struct {
+	status int
+	calls  int
+}
+
+func (c *switchClient) Do(req *http.Request) (*http.Response, error) {
+	c.calls++
+	return &http.Response{StatusCode: c.status, Body: ioutil.NopCloser(strings.NewReader(`{}`))}, nil
+}
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	client := &switchClient{status: 503}
+	l := mocks.NewRecordingLogger()
+	var transitions []string
+	cb := request.NewCircuitBreaker(client, &request.CircuitBreakerParams{
+		Name:             "strava",
+		FailureThreshold: 2,
+		OpenTimeout:      20 * time.Millisecond,
+		Logger:           l,
+		OnStateChange: func(name string, from, to request.BreakerState) {
+			transitions = append(transitions, name+": "+from.String()+" -> "+to.String())
+		},
+	})
+	get := func() error {
+		_, err := request.DefaultR(cb).SetRetries(0).Get("http://mock/v1/path")
+		return err
+	}
+
+	require.True(t, errors.Is(get(), request.ErrServerError), "first failure passes through")
+	require.Equal(t, request.StateClosed, cb.State(), "still closed")
+	require.True(t, errors.Is(get(), request.ErrServerError), "second failure passes through")
+	require.Equal(t, request.StateOpen, cb.State(), "opened")
+	l.ExpectWarn(t, "circuit breaker strava opened")
+
+	require.True(t, errors.Is(get(), request.ErrCircuitOpen), "fails fast while open")
+	require.Equal(t, 2, client.calls, "client not called while open")
+
+	time.Sleep(25 * time.Millisecond)
+	require.Equal(t, request.StateHalfOpen, cb.State(), "half open after timeout")
+	require.True(t, errors.Is(get(), request.ErrServerError), "trial request sent")
+	require.Equal(t, request.StateOpen, cb.State(), "failed trial opens again")
+
+	time.Sleep(25 * time.Millisecond)
+	client.status = 200
+	require.Nil(t, get(), "trial request succeeds")
+	require.Equal(t, request.StateClosed, cb.State(), "closed again")
+	l.ExpectInfo(t, "circuit breaker strava changed from half-open to closed")
+
+	require.Equal(t, []string{
+		"strava: closed -> open",
+		"strava: open -> half-open",
+		"strava: half-open -> open",
+		"strava: open -> half-open",
+		"strava: half-open -> closed",
+	}, transitions, "transitions")
+}
+
+func TestSuccessResetsFailureCount(t *testing.T) {
+	client := &switchClient{status: 500}
+	cb := request.NewCircuitBreaker(client, &request.CircuitBreakerParams{FailureThreshold: 2})
+	req, _ := http.NewRequest(http.MethodGet, "http://mock/v1/path", nil)
+	cb.Do(req)
+	client.status = 200
+	cb.Do(req)
+	client.status = 500
+	cb.Do(req)
+	require.Equal(t, request.StateClosed, cb.State(), "failures must be consecutive")
+	client.status = 404
+	cb.Do(req)
+	require.Equal(t, request.StateClosed, cb.State(), "4xx is not a failure")
+}
+
+func TestOpenCircuitIsNotRetried(t *testing.T) {
+	client := &switchClient{status: 500}
+	cb := request.NewCircuitBreaker(client, &request.CircuitBreakerParams{FailureThreshold: 1, OpenTimeout: time.Hour})
+	_, err := request.DefaultR(cb).SetBackoff(request.ConstantBackoff{}).
+		SetRetryPolicy(func(resp *http.Response, err error) bool { return true }).
+		Get("http://mock/v1/path")
+	require.True(t, errors.Is(err, request.ErrCircuitOpen), "circuit opened during retries")
+	require.False(t, errors.Is(err, request.ErrMaxRetriesExhausted), "not retried once open")
+	require.Equal(t, 1, client.calls, "client called once")
+}
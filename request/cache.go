@@ -0,0 +1,221 @@
+package request
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/serendipity-xyz/common/log"
+)
+
+// FromCacheHeader is set to "1" on responses served from a cache
+const FromCacheHeader = "X-From-Cache"
+
+// CachedResponse is a GET response kept by a Cache
+type CachedResponse struct {
+	Key          string      `json:"key" bson:"key"`
+	URL          string      `json:"url" bson:"url"`
+	StatusCode   int         `json:"statusCode" bson:"statusCode"`
+	Header       http.Header `json:"header" bson:"header"`
+	Body         []byte      `json:"body" bson:"body"`
+	ETag         string      `json:"etag" bson:"etag"`
+	LastModified string      `json:"lastModified" bson:"lastModified"`
+	StoredAt     time.Time   `json:"storedAt" bson:"storedAt"`
+}
+
+// Cache stores responses by key. Implementations must be safe for concurrent use.
+type Cache interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, resp *CachedResponse)
+}
+
+type CachingClientParams struct {
+	// MaxAge serves cached responses younger than this without revalidating them. 0 always
+	// revalidates.
+	MaxAge time.Duration
+}
+
+// CachingClient wraps an HTTPClient and caches GET responses that carry an ETag or
+// Last-Modified header. Cached responses are revalidated with If-None-Match and
+// If-Modified-Since, and a 304 is turned back into the cached response. Responses are
+// keyed by url and Authorization header so users never see each other's data.
+type CachingClient struct {
+	client HTTPClient
+	cache  Cache
+	maxAge time.Duration
+}
+
+func NewCachingClient(client HTTPClient, cache Cache, params *CachingClientParams) *CachingClient {
+	c := &CachingClient{client: client, cache: cache}
+	if params != nil {
+		c.maxAge = params.MaxAge
+	}
+	return c
+}
+
+// CacheKey returns the key a GET request is cached under, a hash of its url and
+// Authorization header
+func CacheKey(req *http.Request) string {
+	h := sha256.New()
+	h.Write([]byte(req.Method + " " + req.URL.String() + "\n" + req.Header.Get("Authorization")))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *CachingClient) Do(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || hasDirective(req.Header, "no-store") {
+		return c.client.Do(req)
+	}
+	key := CacheKey(req)
+	cached, ok := c.cache.Get(key)
+	if ok && c.maxAge > 0 && time.Since(cached.StoredAt) < c.maxAge && !hasDirective(req.Header, "no-cache") {
+		return cached.response(req), nil
+	}
+
+	send := req
+	if ok {
+		send = req.Clone(req.Context())
+		if cached.ETag != "" {
+			send.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			send.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+	resp, err := c.client.Do(send)
+	if err != nil || resp == nil {
+		return resp, err
+	}
+
+	if ok && resp.StatusCode == http.StatusNotModified {
+		discard(resp)
+		if cached.Header == nil {
+			cached.Header = http.Header{}
+		}
+		for k, v := range resp.Header {
+			if k != "Content-Length" {
+				cached.Header[k] = v // a 304 carries updated headers, e.g. Date or Cache-Control
+			}
+		}
+		cached.StoredAt = time.Now()
+		c.cache.Set(key, cached)
+		return cached.response(req), nil
+	}
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if resp.StatusCode != http.StatusOK || (etag == "" && lastModified == "") || hasDirective(resp.Header, "no-store") {
+		return resp, nil
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return resp, err
+	}
+	c.cache.Set(key, &CachedResponse{
+		Key:          key,
+		URL:          log.Redact(req.URL.Redacted(), log.QueryParamRedactor(log.DefaultSecretParams...)),
+		StatusCode:   resp.StatusCode,
+		Header:       resp.Header.Clone(),
+		Body:         body,
+		ETag:         etag,
+		LastModified: lastModified,
+		StoredAt:     time.Now(),
+	})
+	return resp, nil
+}
+
+// response rebuilds an *http.Response for req from the cache
+func (cr *CachedResponse) response(req *http.Request) *http.Response {
+	header := cr.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set(FromCacheHeader, "1")
+	return &http.Response{
+		Status:        http.StatusText(cr.StatusCode),
+		StatusCode:    cr.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(cr.Body)),
+		ContentLength: int64(len(cr.Body)),
+		Request:       req,
+	}
+}
+
+func hasDirective(h http.Header, directive string) bool {
+	for _, v := range h.Values("Cache-Control") {
+		for _, d := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(d), directive) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// LRUCache is an in memory Cache that evicts the least recently used response once it
+// holds Capacity of them
+type LRUCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // of *lruEntry, most recently used first
+	entries  map[string]*list.Element
+}
+
+type lruEntry struct {
+	key  string
+	resp *CachedResponse
+}
+
+func NewLRUCache(capacity int) *LRUCache {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &LRUCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  map[string]*list.Element{},
+	}
+}
+
+func (c *LRUCache) Get(key string) (*CachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	cr := *e.Value.(*lruEntry).resp
+	cr.Header = cr.Header.Clone()
+	return &cr, true
+}
+
+func (c *LRUCache) Set(key string, resp *CachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*lruEntry).resp = resp
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, resp: resp})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
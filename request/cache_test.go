@@ -0,0 +1,89 @@
+package request_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/serendipity-xyz/common/request"
+	"github.com/stretchr/testify/require"
+)
+
+// etagServer answers with an ETag and a 304 when it is sent back
+type etagServer struct {
+	body string
+	etag string
+	seen []*http.Request
+}
+
+func (s *etagServer) Do(req *http.Request) (*http.Response, error) {
+	s.seen = append(s.seen, req)
+	if req.Header.Get("If-None-Match") == s.etag {
+		return &http.Response{StatusCode: http.StatusNotModified, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+	}
+	return &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{"Etag": []string{s.etag}, "Content-Type": []string{"application/json"}},
+		Body:       ioutil.NopCloser(strings.NewReader(s.body)),
+	}, nil
+}
+
+func TestCachingClientRevalidates(t *testing.T) {
+	server := &etagServer{body: `{"id": 1}`, etag: `"v1"`}
+	client := request.NewCachingClient(server, request.NewLRUCache(10), nil)
+
+	for i := 0; i < 2; i++ {
+		var res map[string]int
+		resp, err := request.DefaultR(client).SetResult(&res).Get("http://mock/v1/athlete")
+		require.Nil(t, err, "no error")
+		require.Equal(t, map[string]int{"id": 1}, res, "body decoded")
+		require.Equal(t, 200, resp.StatusCode(), "status")
+		if i == 1 {
+			require.Equal(t, "1", resp.Header().Get(request.FromCacheHeader), "served from cache")
+		}
+	}
+	require.Len(t, server.seen, 2, "revalidated")
+	require.Equal(t, `"v1"`, server.seen[1].Header.Get("If-None-Match"), "etag sent")
+
+	server.body, server.etag = `{"id": 2}`, `"v2"`
+	var res map[string]int
+	resp, err := request.DefaultR(client).SetResult(&res).Get("http://mock/v1/athlete")
+	require.Nil(t, err, "no error")
+	require.Equal(t, map[string]int{"id": 2}, res, "changed body fetched")
+	require.Empty(t, resp.Header().Get(request.FromCacheHeader), "not from cache")
+}
+
+func TestCachingClientMaxAgeAndKeys(t *testing.T) {
+	server := &etagServer{body: `{}`, etag: `"v1"`}
+	client := request.NewCachingClient(server, request.NewLRUCache(10), &request.CachingClientParams{MaxAge: time.Minute})
+	for i := 0; i < 2; i++ {
+		_, err := request.DefaultR(client).Use(request.BearerAuth("user1")).Get("http://mock/v1/athlete")
+		require.Nil(t, err, "no error")
+	}
+	require.Len(t, server.seen, 1, "fresh response served without revalidating")
+
+	_, err := request.DefaultR(client).Use(request.BearerAuth("user2")).Get("http://mock/v1/athlete")
+	require.Nil(t, err, "no error")
+	require.Len(t, server.seen, 2, "other users are not served someone else's response")
+	require.Empty(t, server.seen[1].Header.Get("If-None-Match"), "no cached etag for another user")
+
+	_, err = request.DefaultR(client).SetBody(`{}`).Post("http://mock/v1/athlete")
+	require.Nil(t, err, "no error")
+	require.Len(t, server.seen, 3, "only GETs are cached")
+}
+
+func TestLRUCacheEvicts(t *testing.T) {
+	c := request.NewLRUCache(2)
+	c.Set("a", &request.CachedResponse{StatusCode: 200})
+	c.Set("b", &request.CachedResponse{StatusCode: 200})
+	_, ok := c.Get("a")
+	require.True(t, ok, "a cached")
+	c.Set("c", &request.CachedResponse{StatusCode: 200})
+	require.Equal(t, 2, c.Len(), "capacity respected")
+	_, ok = c.Get("b")
+	require.False(t, ok, "least recently used evicted")
+	_, ok = c.Get("a")
+	require.True(t, ok, "recently used kept")
+}
@@ -0,0 +1,108 @@
+package request
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// CoalescingClient wraps an HTTPClient so concurrent identical GET requests share one call.
+// Requests are identical when their url and Authorization header match, see CacheKey. Every
+// waiter gets its own copy of the response, so each decodes the shared body into its own
+// result. A waiter whose context is done stops waiting, and one whose leader was cancelled
+// sends its request itself.
+type CoalescingClient struct {
+	client HTTPClient
+	mu     sync.Mutex
+	calls  map[string]*flight
+}
+
+// flight is a call in progress that other requests can wait on
+type flight struct {
+	done   chan struct{}
+	status int
+	header http.Header
+	body   []byte
+	err    error
+}
+
+func NewCoalescingClient(client HTTPClient) *CoalescingClient {
+	return &CoalescingClient{client: client, calls: map[string]*flight{}}
+}
+
+func (c *CoalescingClient) Do(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return c.client.Do(req)
+	}
+	key := CacheKey(req)
+	c.mu.Lock()
+	if f, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		return c.wait(req, f)
+	}
+	f := &flight{done: make(chan struct{})}
+	c.calls[key] = f
+	c.mu.Unlock()
+
+	c.fly(req, f)
+	c.mu.Lock()
+	delete(c.calls, key)
+	c.mu.Unlock()
+	close(f.done)
+	return f.response(req)
+}
+
+// fly sends req and keeps the outcome for every waiter
+func (c *CoalescingClient) fly(req *http.Request, f *flight) {
+	resp, err := c.client.Do(req)
+	if err != nil {
+		f.err = err
+		return
+	}
+	if resp == nil {
+		f.err = errors.New("no response received")
+		return
+	}
+	f.status = resp.StatusCode
+	f.header = resp.Header
+	if resp.Body != nil {
+		f.body, f.err = ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+}
+
+func (c *CoalescingClient) wait(req *http.Request, f *flight) (*http.Response, error) {
+	select {
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	case <-f.done:
+	}
+	if isContextErr(f.err) && req.Context().Err() == nil {
+		return c.client.Do(req) // the leader gave up, this request hasn't
+	}
+	return f.response(req)
+}
+
+func (f *flight) response(req *http.Request) (*http.Response, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &http.Response{
+		Status:        http.StatusText(f.status),
+		StatusCode:    f.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        f.header.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader(f.body)),
+		ContentLength: int64(len(f.body)),
+		Request:       req,
+	}, nil
+}
+
+func isContextErr(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,132 @@
+package request_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/serendipity-xyz/common/request"
+	"github.com/stretchr/testify/require"
+)
+
+// gatedServer holds every call until the gate is closed
+type gatedServer struct {
+	gate  chan struct{}
+	calls int32
+}
+
+func (s *gatedServer) Do(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&s.calls, 1)
+	select {
+	case <-s.gate:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+	return &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       ioutil.NopCloser(strings.NewReader(`{"id": 1, "name": "Morning Run"}`)),
+	}, nil
+}
+
+func TestCoalescingClient(t *testing.T) {
+	server := &gatedServer{gate: make(chan struct{})}
+	client := request.NewCoalescingClient(server)
+
+	const waiters = 5
+	results := make([]map[string]interface{}, waiters)
+	errs := make([]error, waiters)
+	var wg sync.WaitGroup
+	for i := 0; i < waiters; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = request.DefaultR(client).SetResult(&results[i]).Get("http://mock/v1/activities/1")
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(server.gate)
+	wg.Wait()
+
+	require.Equal(t, int32(1), atomic.LoadInt32(&server.calls), "one request sent")
+	for i := 0; i < waiters; i++ {
+		require.Nil(t, errs[i], "no error")
+		require.Equal(t, "Morning Run", results[i]["name"], "every waiter decoded the result")
+	}
+	results[0]["name"] = "changed"
+	require.Equal(t, "Morning Run", results[1]["name"], "results are not shared")
+}
+
+func TestCoalescingClientKeys(t *testing.T) {
+	server := &gatedServer{gate: make(chan struct{})}
+	client := request.NewCoalescingClient(server)
+
+	calls := []struct {
+		token string
+		url   string
+	}{
+		{"user1", "http://mock/v1/athlete"},
+		{"user2", "http://mock/v1/athlete"},      // different Authorization header
+		{"user1", "http://mock/v1/activities/1"}, // different url
+		{"user1", "http://mock/v1/athlete"},      // identical to the first
+	}
+	errs := make([]error, len(calls))
+	var wg sync.WaitGroup
+	for i, c := range calls {
+		wg.Add(1)
+		go func(i int, token, url string) {
+			defer wg.Done()
+			_, errs[i] = request.DefaultR(client).Use(request.BearerAuth(token)).Get(url)
+		}(i, c.token, c.url)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(server.gate)
+	wg.Wait()
+
+	for i := range calls {
+		require.Nil(t, errs[i], "no error")
+	}
+	require.Equal(t, int32(3), atomic.LoadInt32(&server.calls), "only identical requests are coalesced")
+}
+
+func TestCoalescingClientSequentialCalls(t *testing.T) {
+	server := &gatedServer{gate: make(chan struct{})}
+	close(server.gate)
+	client := request.NewCoalescingClient(server)
+	_, err := request.DefaultR(client).Use(request.BearerAuth("user1")).Get("http://mock/v1/athlete")
+	require.Nil(t, err, "no error")
+	_, err = request.DefaultR(client).Use(request.BearerAuth("user1")).Get("http://mock/v1/athlete")
+	require.Nil(t, err, "no error")
+	require.Equal(t, int32(2), server.calls, "sequential requests are not coalesced")
+}
+
+func TestCoalescedWaiterOutlivesLeader(t *testing.T) {
+	server := &gatedServer{gate: make(chan struct{})}
+	client := request.NewCoalescingClient(server)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leaderErr := make(chan error)
+	go func() {
+		_, err := request.DefaultR(client).SetContext(ctx).Get("http://mock/v1/athlete")
+		leaderErr <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	var res map[string]interface{}
+	waiterErr := make(chan error)
+	go func() {
+		_, err := request.DefaultR(client).SetResult(&res).Get("http://mock/v1/athlete")
+		waiterErr <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	require.Equal(t, context.Canceled, <-leaderErr, "leader cancelled")
+	close(server.gate)
+	require.Nil(t, <-waiterErr, "waiter sent its own request")
+	require.Equal(t, "Morning Run", res["name"], "waiter got a result")
+}
@@ -0,0 +1,224 @@
+package request
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Encoder turns a request body into a reader and reports the Content-Type to send it with
+type Encoder interface {
+	Encode(v interface{}) (body io.Reader, contentType string, err error)
+}
+
+// Decoder unmarshals a response body into a container
+type Decoder interface {
+	Decode(data []byte, v interface{}) error
+}
+
+type Codec interface {
+	Encoder
+	Decoder
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[string]Codec{
+		"application/json":                  JSONCodec{},
+		"application/x-www-form-urlencoded": FormCodec{},
+	}
+)
+
+// RegisterCodec sets the codec used for a media type, e.g. "application/xml". Requests
+// whose Content-Type header has that media type are encoded with it and responses with it
+// are decoded with it, unless a codec was set on the request. JSON is used for anything
+// unregistered.
+func RegisterCodec(mediaType string, c Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[strings.ToLower(mediaType)] = c
+}
+
+func codecFor(contentType string) Codec {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return JSONCodec{}
+	}
+	codecsMu.RLock()
+	c, ok := codecs[mediaType]
+	codecsMu.RUnlock()
+	if !ok {
+		return JSONCodec{}
+	}
+	return c
+}
+
+// JSONCodec encodes and decodes JSON
+type JSONCodec struct{}
+
+func (JSONCodec) Encode(v interface{}) (io.Reader, string, error) {
+	b := new(bytes.Buffer)
+	if err := json.NewEncoder(b).Encode(v); err != nil {
+		return nil, "", err
+	}
+	return b, "application/json", nil
+}
+
+func (JSONCodec) Decode(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// FormCodec encodes url.Values, map[string]string or map[string]interface{} bodies as
+// application/x-www-form-urlencoded and decodes into *url.Values or *map[string]string
+type FormCodec struct{}
+
+func (FormCodec) Encode(v interface{}) (io.Reader, string, error) {
+	values := url.Values{}
+	switch body := v.(type) {
+	case url.Values:
+		values = body
+	case map[string]string:
+		for k, val := range body {
+			values.Set(k, val)
+		}
+	case map[string]interface{}:
+		for k, val := range body {
+			values[k] = queryValues(val)
+		}
+	default:
+		return nil, "", fmt.Errorf("unable to form encode %T", v)
+	}
+	return strings.NewReader(values.Encode()), "application/x-www-form-urlencoded", nil
+}
+
+func (FormCodec) Decode(data []byte, v interface{}) error {
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return err
+	}
+	switch container := v.(type) {
+	case *url.Values:
+		*container = values
+	case *map[string]string:
+		m := make(map[string]string, len(values))
+		for k := range values {
+			m[k] = values.Get(k)
+		}
+		*container = m
+	default:
+		return fmt.Errorf("unable to form decode into %T", v)
+	}
+	return nil
+}
+
+// Multipart is a multipart/form-data body, e.g. for file uploads
+type Multipart struct {
+	Fields map[string]string
+	Files  []MultipartFile
+}
+
+type MultipartFile struct {
+	Field       string // form field name
+	FileName    string
+	ContentType string // defaults to application/octet-stream
+	Content     io.Reader
+}
+
+// MultipartCodec encodes a Multipart (or *Multipart) body. The whole body is buffered so
+// it can be re-sent on retry. Decoding is not supported.
+type MultipartCodec struct{}
+
+func (MultipartCodec) Encode(v interface{}) (io.Reader, string, error) {
+	var m Multipart
+	switch body := v.(type) {
+	case Multipart:
+		m = body
+	case *Multipart:
+		m = *body
+	default:
+		return nil, "", fmt.Errorf("unable to multipart encode %T", v)
+	}
+	b := new(bytes.Buffer)
+	w := multipart.NewWriter(b)
+	for k, val := range m.Fields {
+		if err := w.WriteField(k, val); err != nil {
+			return nil, "", err
+		}
+	}
+	for _, f := range m.Files {
+		contentType := f.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, escapeQuotes(f.Field), escapeQuotes(f.FileName)))
+		h.Set("Content-Type", contentType)
+		part, err := w.CreatePart(h)
+		if err != nil {
+			return nil, "", err
+		}
+		if f.Content != nil {
+			if _, err := io.Copy(part, f.Content); err != nil {
+				return nil, "", fmt.Errorf("unable to read multipart file %v: %v", f.FileName, err)
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return b, w.FormDataContentType(), nil
+}
+
+func (MultipartCodec) Decode(data []byte, v interface{}) error {
+	return fmt.Errorf("multipart responses are not supported")
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
+
+// RawCodec sends []byte, string or io.Reader bodies as is and decodes into *[]byte,
+// *string or an io.Writer. An io.Reader body is streamed, so the request won't be retried.
+type RawCodec struct {
+	ContentType string // defaults to application/octet-stream
+}
+
+func (c RawCodec) Encode(v interface{}) (io.Reader, string, error) {
+	contentType := c.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	switch body := v.(type) {
+	case []byte:
+		return bytes.NewReader(body), contentType, nil
+	case string:
+		return strings.NewReader(body), contentType, nil
+	case io.Reader:
+		return body, contentType, nil
+	}
+	return nil, "", fmt.Errorf("unable to send %T as a raw body", v)
+}
+
+func (RawCodec) Decode(data []byte, v interface{}) error {
+	switch container := v.(type) {
+	case *[]byte:
+		*container = append([]byte(nil), data...)
+	case *string:
+		*container = string(data)
+	case io.Writer:
+		_, err := io.Copy(container, bytes.NewReader(data))
+		return err
+	default:
+		return fmt.Errorf("unable to raw decode into %T", v)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package request_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/serendipity-xyz/common/request"
+	"github.com/stretchr/testify/require"
+)
+
+func respondWith(contentType, body string, seen *[]*http.Request) clientFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		*seen = append(*seen, req)
+		return &http.Response{
+			StatusCode: 200,
+			Header:     http.Header{"Content-Type": []string{contentType}},
+			Body:       ioutil.NopCloser(strings.NewReader(body)),
+		}, nil
+	}
+}
+
+func TestFormBody(t *testing.T) {
+	var seen []*http.Request
+	var res map[string]interface{}
+	_, err := request.DefaultR(respondWith("application/json", `{"access_token": "abc"}`, &seen)).
+		SetResult(&res).
+		SetFormBody(url.Values{"grant_type": {"refresh_token"}, "refresh_token": {"a&b"}}).
+		Post("mockURL/oauth/token")
+	require.Nil(t, err, "no error")
+	require.Equal(t, "application/x-www-form-urlencoded", seen[0].Header.Get("Content-Type"), "content type replaces the json default")
+	body, _ := ioutil.ReadAll(seen[0].Body)
+	require.Equal(t, "grant_type=refresh_token&refresh_token=a%26b", string(body), "form encoded")
+	require.Equal(t, map[string]interface{}{"access_token": "abc"}, res, "json response decoded")
+}
+
+func TestEncoderFromContentTypeHeader(t *testing.T) {
+	var seen []*http.Request
+	_, err := request.DefaultR(respondWith("application/json", `{}`, &seen)).
+		SetHeader("Content-Type", "application/x-www-form-urlencoded").
+		SetBody(map[string]string{"a": "1"}).
+		Post("mockURL/v1/path")
+	require.Nil(t, err, "no error")
+	body, _ := ioutil.ReadAll(seen[0].Body)
+	require.Equal(t, "a=1", string(body), "form encoded because of the header")
+}
+
+func TestMultipartBody(t *testing.T) {
+	var seen []*http.Request
+	_, err := request.DefaultR(respondWith("application/json", `{"id": 1}`, &seen)).
+		SetMultipartBody(&request.Multipart{
+			Fields: map[string]string{"data_type": "gpx"},
+			Files: []request.MultipartFile{
+				{Field: "file", FileName: "run.gpx", ContentType: "application/gpx+xml", Content: strings.NewReader("<gpx/>")},
+			},
+		}).
+		Post("mockURL/uploads")
+	require.Nil(t, err, "no error")
+	mediaType, params, err := mime.ParseMediaType(seen[0].Header.Get("Content-Type"))
+	require.Nil(t, err, "valid content type")
+	require.Equal(t, "multipart/form-data", mediaType, "media type")
+	form, err := multipart.NewReader(seen[0].Body, params["boundary"]).ReadForm(1 << 20)
+	require.Nil(t, err, "valid multipart body")
+	require.Equal(t, []string{"gpx"}, form.Value["data_type"], "field")
+	f, err := form.File["file"][0].Open()
+	require.Nil(t, err, "file part")
+	content, _ := ioutil.ReadAll(f)
+	require.Equal(t, "<gpx/>", string(content), "file content")
+	require.Equal(t, "run.gpx", form.File["file"][0].Filename, "file name")
+}
+
+func TestRawBodyAndResult(t *testing.T) {
+	var seen []*http.Request
+	var res []byte
+	_, err := request.DefaultR(respondWith("application/gpx+xml", `<gpx>...</gpx>`, &seen)).
+		SetCodec(request.RawCodec{ContentType: "text/plain"}).
+		SetResult(&res).
+		SetBody([]byte("hello")).
+		Put("mockURL/v1/path")
+	require.Nil(t, err, "no error")
+	require.Equal(t, "text/plain", seen[0].Header.Get("Content-Type"), "raw content type")
+	body, _ := ioutil.ReadAll(seen[0].Body)
+	require.Equal(t, "hello", string(body), "raw body")
+	require.Equal(t, []byte(`<gpx>...</gpx>`), res, "raw result")
+}
+
+func TestDecoderFromResponseContentType(t *testing.T) {
+	var seen []*http.Request
+	var res url.Values
+	_, err := request.DefaultR(respondWith("application/x-www-form-urlencoded; charset=utf-8", `a=1&b=2`, &seen)).
+		SetResult(&res).
+		Get("mockURL/v1/path")
+	require.Nil(t, err, "no error")
+	require.Equal(t, url.Values{"a": {"1"}, "b": {"2"}}, res, "form decoded")
+}
+
+func TestStreamedRawBodyIsNotRetried(t *testing.T) {
+	calls := 0
+	client := clientFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{StatusCode: 503, Body: ioutil.NopCloser(bytes.NewReader(nil))}, nil
+	})
+	_, err := request.DefaultR(client).
+		SetRawBody(ioutil.NopCloser(strings.NewReader("stream")), "application/octet-stream").
+		Put("mockURL/v1/path")
+	require.IsType(t, request.BadStatusError{}, err, "bad status returned instead of retrying")
+	require.Equal(t, 1, calls, "call count")
+}
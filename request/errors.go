@@ -0,0 +1,108 @@
+package request
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/serendipity-xyz/common/log"
+)
+
+// maxBodySnippet is how much of a bad response's body is kept on the error
+const maxBodySnippet = 512
+
+// Categories a BadStatusError can be matched against with errors.Is, e.g.
+// errors.Is(err, request.ErrUnauthorized)
+var (
+	ErrUnauthorized = errors.New("unauthorized") // 401
+	ErrForbidden    = errors.New("forbidden")    // 403
+	ErrNotFound     = errors.New("not found")    // 404
+	ErrRateLimited  = errors.New("rate limited") // 429
+	ErrClientError  = errors.New("client error") // any 4xx
+	ErrServerError  = errors.New("server error") // any 5xx
+)
+
+// BadStatusError is returned when the final response has a status of 400 or above. Use
+// errors.As to get at the details and errors.Is to check its category.
+type BadStatusError struct {
+	code     int
+	method   string
+	url      string
+	header   http.Header
+	body     []byte
+	attempts int
+}
+
+func newBadStatusError(req *http.Request, resp *http.Response, body []byte, attempts int) BadStatusError {
+	if len(body) > maxBodySnippet {
+		body = body[:maxBodySnippet]
+		for len(body) > 0 && !utf8.Valid(body) {
+			body = body[:len(body)-1] // don't cut a rune in half
+		}
+	}
+	return BadStatusError{
+		code:     resp.StatusCode,
+		method:   req.Method,
+		url:      log.Redact(req.URL.Redacted(), log.QueryParamRedactor(log.DefaultSecretParams...)),
+		header:   resp.Header,
+		body:     append([]byte(nil), body...),
+		attempts: attempts,
+	}
+}
+
+func (e BadStatusError) Error() string {
+	msg := fmt.Sprintf("bad status code: %v", e.code)
+	if e.method != "" {
+		msg += fmt.Sprintf(" (%v %v)", e.method, e.url)
+	}
+	if len(e.body) > 0 {
+		msg += ": " + log.Redact(string(e.body), log.DefaultRedactors()...)
+	}
+	return msg
+}
+
+func (e BadStatusError) Code() int {
+	return e.code
+}
+
+func (e BadStatusError) Method() string {
+	return e.method
+}
+
+// URL returns the url the request was sent to with secrets such as access tokens masked
+func (e BadStatusError) URL() string {
+	return e.url
+}
+
+func (e BadStatusError) Header() http.Header {
+	return e.header
+}
+
+// Body returns up to the first 512 bytes of the response body
+func (e BadStatusError) Body() []byte {
+	return e.body
+}
+
+// Attempts returns how many times the request was sent before giving up
+func (e BadStatusError) Attempts() int {
+	return e.attempts
+}
+
+func (e BadStatusError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.code == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.code == http.StatusForbidden
+	case ErrNotFound:
+		return e.code == http.StatusNotFound
+	case ErrRateLimited:
+		return e.code == http.StatusTooManyRequests
+	case ErrClientError:
+		return e.code >= 400 && e.code < 500
+	case ErrServerError:
+		return e.code >= 500
+	}
+	return false
+}
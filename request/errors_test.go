@@ -0,0 +1,74 @@
+package request_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/serendipity-xyz/common/request"
+	"github.com/stretchr/testify/require"
+)
+
+func statusClient(code int, body string) clientFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: code,
+			Header:     http.Header{"X-Mock": []string{"yes"}},
+			Body:       ioutil.NopCloser(strings.NewReader(body)),
+		}, nil
+	}
+}
+
+func TestBadStatusCategories(t *testing.T) {
+	tests := []struct {
+		code  int
+		is    []error
+		isNot []error
+	}{
+		{401, []error{request.ErrUnauthorized, request.ErrClientError}, []error{request.ErrForbidden, request.ErrServerError}},
+		{403, []error{request.ErrForbidden, request.ErrClientError}, []error{request.ErrUnauthorized}},
+		{404, []error{request.ErrNotFound, request.ErrClientError}, []error{request.ErrServerError}},
+		{422, []error{request.ErrClientError}, []error{request.ErrNotFound, request.ErrRateLimited}},
+		{501, []error{request.ErrServerError}, []error{request.ErrClientError}},
+	}
+	for _, tt := range tests {
+		_, err := request.DefaultR(statusClient(tt.code, "")).SetRetries(0).Get("http://mock/v1/path")
+		for _, target := range tt.is {
+			require.True(t, errors.Is(err, target), "%v should be %v", tt.code, target)
+		}
+		for _, target := range tt.isNot {
+			require.False(t, errors.Is(err, target), "%v should not be %v", tt.code, target)
+		}
+	}
+}
+
+func TestBadStatusDetails(t *testing.T) {
+	body := `{"message": "Authorization Error", "access_token": "abc123"}` + strings.Repeat("x", 1000)
+	_, err := request.DefaultR(statusClient(401, body)).Get("http://mock/v1/path?access_token=abc123&page=2")
+	require.IsType(t, request.BadStatusError{}, err, "bad status error")
+
+	var bse request.BadStatusError
+	require.True(t, errors.As(err, &bse), "errors.As")
+	require.Equal(t, 401, bse.Code(), "code")
+	require.Equal(t, http.MethodGet, bse.Method(), "method")
+	require.Equal(t, "http://mock/v1/path?access_token=[REDACTED]&page=2", bse.URL(), "token redacted from url")
+	require.Equal(t, "yes", bse.Header().Get("X-Mock"), "headers kept")
+	require.Len(t, bse.Body(), 512, "body snippet is capped")
+	require.Equal(t, 1, bse.Attempts(), "attempts")
+	require.NotContains(t, err.Error(), "abc123", "secrets redacted from message")
+	require.Contains(t, err.Error(), "bad status code: 401 (GET http://mock/v1/path?access_token=[REDACTED]&page=2)", "message")
+}
+
+func TestRetriesExhaustedWrapsBadStatus(t *testing.T) {
+	_, err := request.DefaultR(statusClient(503, "down")).SetBackoff(request.ConstantBackoff{}).Get("http://mock/v1/path")
+	require.Equal(t, "max retries exhausted: bad status code: 503 (GET http://mock/v1/path): down", err.Error(), "message includes the last status")
+	require.True(t, errors.Is(err, request.ErrMaxRetriesExhausted), "retries exhausted")
+	require.True(t, errors.Is(err, request.ErrServerError), "server error")
+
+	var bse request.BadStatusError
+	require.True(t, errors.As(err, &bse), "errors.As")
+	require.Equal(t, 3, bse.Attempts(), "attempts")
+	require.Equal(t, "down", string(bse.Body()), "body")
+}
@@ -0,0 +1,204 @@
+package request
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/serendipity-xyz/common/log"
+)
+
+// Interceptor hooks into every attempt a request makes, e.g. to add auth or tracing headers,
+// log calls or record metrics. BeforeSend may modify the request and aborts the call if it
+// returns an error. AfterReceive sees the outcome of the attempt, before any retry, and
+// returns the response and error the request carries on with.
+//
+// BeforeSend hooks run in the order the interceptors were added and AfterReceive hooks in
+// the reverse order, so the first interceptor added wraps all the others.
+type Interceptor interface {
+	BeforeSend(req *http.Request) error
+	AfterReceive(req *http.Request, resp *http.Response, err error) (*http.Response, error)
+}
+
+// InterceptorFuncs builds an Interceptor from functions. Either may be nil.
+type InterceptorFuncs struct {
+	Before func(req *http.Request) error
+	After  func(req *http.Request, resp *http.Response, err error) (*http.Response, error)
+}
+
+func (i InterceptorFuncs) BeforeSend(req *http.Request) error {
+	if i.Before == nil {
+		return nil
+	}
+	return i.Before(req)
+}
+
+func (i InterceptorFuncs) AfterReceive(req *http.Request, resp *http.Response, err error) (*http.Response, error) {
+	if i.After == nil {
+		return resp, err
+	}
+	return i.After(req, resp, err)
+}
+
+// Use adds interceptors to the request
+func (r *request) Use(interceptors ...Interceptor) *request {
+	r.interceptors = append(r.interceptors, interceptors...)
+	return r
+}
+
+// send makes a single attempt through the interceptor chain
+func (r *request) send(req *http.Request) (*http.Response, error) {
+	return intercept(r.client, r.interceptors, req)
+}
+
+func intercept(client HTTPClient, interceptors []Interceptor, req *http.Request) (*http.Response, error) {
+	for i, ic := range interceptors {
+		if err := ic.BeforeSend(req); err != nil {
+			return unwind(interceptors[:i], req, nil, err)
+		}
+	}
+	resp, err := client.Do(req)
+	return unwind(interceptors, req, resp, err)
+}
+
+func unwind(interceptors []Interceptor, req *http.Request, resp *http.Response, err error) (*http.Response, error) {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		resp, err = interceptors[i].AfterReceive(req, resp, err)
+	}
+	return resp, err
+}
+
+type interceptedClient struct {
+	client       HTTPClient
+	interceptors []Interceptor
+}
+
+// Intercept wraps client so every request sent through it, with or without the request
+// builder, goes through interceptors. Handy for setting up a client once and sharing it.
+func Intercept(client HTTPClient, interceptors ...Interceptor) HTTPClient {
+	return &interceptedClient{client: client, interceptors: interceptors}
+}
+
+func (c *interceptedClient) Do(req *http.Request) (*http.Response, error) {
+	return intercept(c.client, c.interceptors, req)
+}
+
+// HeaderInterceptor sets a header on every attempt
+func HeaderInterceptor(key, value string) Interceptor {
+	return InterceptorFuncs{Before: func(req *http.Request) error {
+		req.Header.Set(key, value)
+		return nil
+	}}
+}
+
+// BearerAuth sends token in an Authorization header
+func BearerAuth(token string) Interceptor {
+	return HeaderInterceptor("Authorization", "Bearer "+token)
+}
+
+// BearerAuthFunc looks the token up on every attempt, so a token refreshed between retries
+// or between calls is picked up
+func BearerAuthFunc(token func(req *http.Request) (string, error)) Interceptor {
+	return InterceptorFuncs{Before: func(req *http.Request) error {
+		t, err := token(req)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+t)
+		return nil
+	}}
+}
+
+// TransactionIDInterceptor forwards the transaction ID on the request's context, see
+// log.ContextWithTransactionID, in a log.TransactionIDHeader header so calls can be traced
+// across services. A header that is already set is left alone.
+func TransactionIDInterceptor() Interceptor {
+	return InterceptorFuncs{Before: func(req *http.Request) error {
+		if req.Header.Get(log.TransactionIDHeader) != "" {
+			return nil
+		}
+		if id := log.TransactionIDFromContext(req.Context()); id != "" {
+			req.Header.Set(log.TransactionIDHeader, id)
+		}
+		return nil
+	}}
+}
+
+// attemptTimer remembers when each in-flight attempt started
+type attemptTimer struct {
+	starts sync.Map
+}
+
+func (t *attemptTimer) start(req *http.Request) {
+	t.starts.Store(req, time.Now())
+}
+
+func (t *attemptTimer) stop(req *http.Request) time.Duration {
+	v, ok := t.starts.LoadAndDelete(req)
+	if !ok {
+		return 0
+	}
+	return time.Since(v.(time.Time))
+}
+
+// LoggingInterceptor logs every attempt at debug level, and failed ones at warn level, with
+// secrets masked from the url and errors
+func LoggingInterceptor(l log.Logger) Interceptor {
+	timer := &attemptTimer{}
+	return InterceptorFuncs{
+		Before: func(req *http.Request) error {
+			timer.start(req)
+			return nil
+		},
+		After: func(req *http.Request, resp *http.Response, err error) (*http.Response, error) {
+			latency := timer.stop(req).Round(time.Millisecond)
+			logger := log.WithTransactionID(l, log.TransactionIDFromContext(req.Context()))
+			u := log.Redact(req.URL.Redacted(), log.QueryParamRedactor(log.DefaultSecretParams...))
+			switch {
+			case err != nil:
+				// transport errors such as *url.Error repeat the full url
+				logger.Warn("%v %v failed after %v: %v", req.Method, u, latency, log.Redact(err.Error(), log.DefaultRedactors()...))
+			case resp != nil && resp.StatusCode > 399:
+				logger.Warn("%v %v returned %v in %v", req.Method, u, resp.StatusCode, latency)
+			case resp != nil:
+				logger.Debug("%v %v returned %v in %v", req.Method, u, resp.StatusCode, latency)
+			}
+			return resp, err
+		},
+	}
+}
+
+// Metric describes a single attempt, see MetricsInterceptor
+type Metric struct {
+	Method     string
+	Host       string
+	Path       string
+	StatusCode int // 0 if no response was received
+	Latency    time.Duration
+	Err        error
+}
+
+// MetricsInterceptor calls observe after every attempt, e.g. to feed a latency histogram
+func MetricsInterceptor(observe func(Metric)) Interceptor {
+	timer := &attemptTimer{}
+	return InterceptorFuncs{
+		Before: func(req *http.Request) error {
+			timer.start(req)
+			return nil
+		},
+		After: func(req *http.Request, resp *http.Response, err error) (*http.Response, error) {
+			m := Metric{
+				Method:  req.Method,
+				Host:    req.URL.Host,
+				Path:    req.URL.Path,
+				Latency: timer.stop(req),
+				Err:     err,
+			}
+			if resp != nil {
+				m.StatusCode = resp.StatusCode
+			}
+			observe(m)
+			return resp, err
+		},
+	}
+}
@@ -0,0 +1,112 @@
+package request_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/serendipity-xyz/common/log"
+	"github.com/serendipity-xyz/common/mocks"
+	"github.com/serendipity-xyz/common/request"
+	"github.com/stretchr/testify/require"
+)
+
+func recordingInterceptor(name string, calls *[]string) request.Interceptor {
+	return request.InterceptorFuncs{
+		Before: func(req *http.Request) error {
+			*calls = append(*calls, "before "+name)
+			return nil
+		},
+		After: func(req *http.Request, resp *http.Response, err error) (*http.Response, error) {
+			*calls = append(*calls, "after "+name)
+			return resp, err
+		},
+	}
+}
+
+func TestInterceptorOrder(t *testing.T) {
+	var seen []*http.Request
+	var calls []string
+	_, err := request.DefaultR(okClient(&seen)).
+		Use(recordingInterceptor("a", &calls), recordingInterceptor("b", &calls)).
+		Use(request.BearerAuth("token"), request.HeaderInterceptor("X-Client", "common")).
+		Get("http://mock/v1/path")
+	require.Nil(t, err, "no error")
+	require.Equal(t, []string{"before a", "before b", "after b", "after a"}, calls, "first interceptor wraps the rest")
+	require.Equal(t, "Bearer token", seen[0].Header.Get("Authorization"), "auth header")
+	require.Equal(t, "common", seen[0].Header.Get("X-Client"), "custom header")
+}
+
+func TestInterceptorRunsOnEveryAttempt(t *testing.T) {
+	httpClient := mocks.NewRequestMock(&mocks.NewRequestMockOpts{
+		Responses: []*http.Response{
+			{StatusCode: 500, Body: ioutil.NopCloser(strings.NewReader(""))},
+			{StatusCode: 200, Body: ioutil.NopCloser(strings.NewReader(`{}`))},
+		},
+	})
+	var metrics []request.Metric
+	_, err := request.DefaultR(httpClient).SetBackoff(request.ConstantBackoff{}).
+		Use(request.MetricsInterceptor(func(m request.Metric) { metrics = append(metrics, m) })).
+		Get("http://mock/v1/path")
+	require.Nil(t, err, "no error")
+	require.Len(t, metrics, 2, "one metric per attempt")
+	require.Equal(t, 500, metrics[0].StatusCode, "first attempt")
+	require.Equal(t, 200, metrics[1].StatusCode, "second attempt")
+	require.Equal(t, "mock", metrics[1].Host, "host")
+	require.Equal(t, "/v1/path", metrics[1].Path, "path")
+}
+
+func TestBeforeSendErrorAborts(t *testing.T) {
+	var seen []*http.Request
+	var calls []string
+	failing := request.InterceptorFuncs{Before: func(req *http.Request) error {
+		return errors.New("no token")
+	}}
+	_, err := request.DefaultR(okClient(&seen)).
+		Use(recordingInterceptor("a", &calls), failing, recordingInterceptor("b", &calls)).
+		Get("http://mock/v1/path")
+	require.EqualError(t, err, "no token", "error from interceptor")
+	require.Empty(t, seen, "request not sent")
+	require.Equal(t, []string{"before a", "after a"}, calls, "only interceptors that ran are unwound")
+}
+
+func TestAfterReceiveCanReplaceOutcome(t *testing.T) {
+	var seen []*http.Request
+	blocked := errors.New("blocked")
+	_, err := request.DefaultR(okClient(&seen)).
+		Use(request.InterceptorFuncs{After: func(req *http.Request, resp *http.Response, err error) (*http.Response, error) {
+			return nil, blocked
+		}}).
+		Get("http://mock/v1/path")
+	require.Equal(t, blocked, err, "error from interceptor")
+}
+
+func TestTransactionIDAndLoggingInterceptors(t *testing.T) {
+	var seen []*http.Request
+	l := mocks.NewRecordingLogger()
+	ctx := log.ContextWithTransactionID(context.Background(), "trx-1")
+	client := request.Intercept(okClient(&seen), request.TransactionIDInterceptor(), request.LoggingInterceptor(l))
+	_, err := request.DefaultR(client).SetContext(ctx).
+		SetQueryParam("access_token", "secret").
+		Get("http://mock/v1/path")
+	require.Nil(t, err, "no error")
+	require.Equal(t, "trx-1", seen[0].Header.Get(log.TransactionIDHeader), "transaction id forwarded")
+	l.ExpectDebug(t, "GET http://mock/v1/path?access_token=[REDACTED] returned 200")
+	require.Equal(t, "trx-1", l.Entries()[0].TransactionID, "logged with transaction id")
+	require.False(t, l.Contains(log.DebugLevel, "secret"), "token not logged")
+
+	failing := request.Intercept(clientFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, &url.Error{Op: "Get", URL: req.URL.String(), Err: errors.New("dial tcp 127.0.0.1:1: connect: connection refused")}
+	}), request.LoggingInterceptor(l))
+	_, err = request.DefaultR(failing).SetContext(ctx).SetRetryPolicy(nil).
+		SetQueryParam("access_token", "secret").
+		Get("http://mock/v1/path")
+	require.NotNil(t, err, "transport error")
+	l.ExpectWarn(t, `GET http://mock/v1/path?access_token=[REDACTED] failed after`)
+	l.ExpectWarn(t, `Get "http://mock/v1/path?access_token=[REDACTED]": dial tcp`)
+	require.False(t, l.Contains(log.WarnLevel, "secret"), "token not logged from the error")
+}
@@ -0,0 +1,195 @@
+package request
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrRateLimitExceeded is returned by a fail fast RateLimiter when sending a request now
+// would go over one of its limits. Unlike ErrRateLimited the request was never sent.
+var ErrRateLimitExceeded = errors.New("client rate limit exceeded")
+
+// Limit allows Requests requests per Window
+type Limit struct {
+	Requests int
+	Window   time.Duration
+}
+
+type RateLimiterParams struct {
+	// Limits are all enforced at once, e.g. 100 per 15 minutes and 1000 per day
+	Limits []Limit
+	// FailFast returns ErrRateLimitExceeded instead of waiting for the limit to free up
+	FailFast bool
+	// LimitHeader and UsageHeader name response headers holding comma separated limits and
+	// usage, one per entry in Limits and in the same order, e.g. X-RateLimit-Limit: 100,1000
+	// and X-RateLimit-Usage: 5,20. When set the limiter recalibrates from every response so
+	// requests made by other processes are accounted for.
+	LimitHeader string
+	UsageHeader string
+}
+
+// RateLimiter is a token bucket limiter for outbound requests. Share one between every
+// client that counts against the same limits and add it with Use or Intercept.
+type RateLimiter struct {
+	mu          sync.Mutex
+	buckets     []*bucket
+	failFast    bool
+	limitHeader string
+	usageHeader string
+}
+
+type bucket struct {
+	capacity float64
+	tokens   float64
+	window   time.Duration
+	last     time.Time
+}
+
+func NewRateLimiter(params *RateLimiterParams) *RateLimiter {
+	rl := &RateLimiter{
+		failFast:    params.FailFast,
+		limitHeader: params.LimitHeader,
+		usageHeader: params.UsageHeader,
+	}
+	now := time.Now()
+	for _, l := range params.Limits {
+		if l.Requests <= 0 || l.Window <= 0 {
+			continue
+		}
+		rl.buckets = append(rl.buckets, &bucket{
+			capacity: float64(l.Requests),
+			tokens:   float64(l.Requests),
+			window:   l.Window,
+			last:     now,
+		})
+	}
+	return rl
+}
+
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last)
+	if elapsed <= 0 {
+		return
+	}
+	b.tokens += b.capacity * float64(elapsed) / float64(b.window)
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.last = now
+}
+
+// wait returns how long until the bucket has a token
+func (b *bucket) wait() time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) * float64(b.window) / b.capacity)
+}
+
+// reserve takes a token from every bucket, or none and returns how long to wait if any
+// bucket is empty
+func (rl *RateLimiter) reserve() time.Duration {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	now := time.Now()
+	var d time.Duration
+	for _, b := range rl.buckets {
+		b.refill(now)
+		if w := b.wait(); w > d {
+			d = w
+		}
+	}
+	if d > 0 {
+		return d
+	}
+	for _, b := range rl.buckets {
+		b.tokens--
+	}
+	return 0
+}
+
+// Allow takes a token if one is available without waiting
+func (rl *RateLimiter) Allow() bool {
+	return rl.reserve() == 0
+}
+
+// Wait takes a token, blocking until one is available or ctx is done. A fail fast limiter
+// returns ErrRateLimitExceeded straight away instead.
+func (rl *RateLimiter) Wait(ctx context.Context) error {
+	for {
+		d := rl.reserve()
+		if d == 0 {
+			return nil
+		}
+		if rl.failFast {
+			return fmt.Errorf("%w: next request allowed in %v", ErrRateLimitExceeded, d.Round(time.Millisecond))
+		}
+		if err := wait(ctx, d); err != nil {
+			return err
+		}
+	}
+}
+
+// Calibrate resets the limiter from the limit and usage headers of a response, if it was
+// created with LimitHeader and UsageHeader
+func (rl *RateLimiter) Calibrate(h http.Header) {
+	if rl.limitHeader == "" || rl.usageHeader == "" || h == nil {
+		return
+	}
+	limits := parseCounts(h.Get(rl.limitHeader))
+	usage := parseCounts(h.Get(rl.usageHeader))
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	now := time.Now()
+	for i, b := range rl.buckets {
+		if i >= len(usage) || usage[i] < 0 {
+			continue
+		}
+		if i < len(limits) && limits[i] > 0 {
+			b.capacity = float64(limits[i])
+		}
+		b.tokens = b.capacity - float64(usage[i])
+		if b.tokens < 0 {
+			b.tokens = 0
+		}
+		b.last = now
+	}
+}
+
+// parseCounts parses "100,1000", returning -1 for anything that isn't a number
+func parseCounts(v string) []int {
+	if strings.TrimSpace(v) == "" {
+		return nil
+	}
+	var counts []int
+	for _, s := range strings.Split(v, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			n = -1
+		}
+		counts = append(counts, n)
+	}
+	return counts
+}
+
+// Interceptor waits for the limiter before every attempt, retries included, and
+// recalibrates it from every response
+func (rl *RateLimiter) Interceptor() Interceptor {
+	return InterceptorFuncs{
+		Before: func(req *http.Request) error {
+			return rl.Wait(req.Context())
+		},
+		After: func(req *http.Request, resp *http.Response, err error) (*http.Response, error) {
+			if resp != nil {
+				rl.Calibrate(resp.Header)
+			}
+			return resp, err
+		},
+	}
+}
@@ -0,0 +1,80 @@
+package request_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/serendipity-xyz/common/request"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiterEnforcesEveryLimit(t *testing.T) {
+	rl := request.NewRateLimiter(&request.RateLimiterParams{
+		Limits: []request.Limit{
+			{Requests: 3, Window: time.Hour},
+			{Requests: 2, Window: time.Hour},
+		},
+	})
+	require.True(t, rl.Allow(), "first request")
+	require.True(t, rl.Allow(), "second request")
+	require.False(t, rl.Allow(), "tightest limit reached")
+}
+
+func TestRateLimiterBlocks(t *testing.T) {
+	rl := request.NewRateLimiter(&request.RateLimiterParams{
+		Limits: []request.Limit{{Requests: 1, Window: 50 * time.Millisecond}},
+	})
+	start := time.Now()
+	require.Nil(t, rl.Wait(context.Background()), "first request")
+	require.Nil(t, rl.Wait(context.Background()), "second request")
+	require.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond, "waited for a token")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.Equal(t, context.DeadlineExceeded, rl.Wait(ctx), "gives up with the context")
+}
+
+func TestRateLimiterFailFast(t *testing.T) {
+	var seen []*http.Request
+	rl := request.NewRateLimiter(&request.RateLimiterParams{
+		Limits:   []request.Limit{{Requests: 1, Window: time.Hour}},
+		FailFast: true,
+	})
+	_, err := request.DefaultR(okClient(&seen)).Use(rl.Interceptor()).Get("http://mock/v1/path")
+	require.Nil(t, err, "first request")
+	_, err = request.DefaultR(okClient(&seen)).Use(rl.Interceptor()).Get("http://mock/v1/path")
+	require.True(t, errors.Is(err, request.ErrRateLimitExceeded), "limit exceeded")
+	require.False(t, errors.Is(err, request.ErrMaxRetriesExhausted), "not retried")
+	require.Len(t, seen, 1, "second request not sent")
+}
+
+func TestRateLimiterCalibratesFromHeaders(t *testing.T) {
+	rl := request.NewRateLimiter(&request.RateLimiterParams{
+		Limits: []request.Limit{
+			{Requests: 100, Window: 15 * time.Minute},
+			{Requests: 1000, Window: 24 * time.Hour},
+		},
+		LimitHeader: "X-RateLimit-Limit",
+		UsageHeader: "X-RateLimit-Usage",
+	})
+	client := clientFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: 200,
+			Header: http.Header{
+				"X-Ratelimit-Limit": []string{"100,1000"},
+				"X-Ratelimit-Usage": []string{"98,400"},
+			},
+			Body: ioutil.NopCloser(strings.NewReader(`{}`)),
+		}, nil
+	})
+	_, err := request.DefaultR(client).Use(rl.Interceptor()).Get("http://mock/v1/path")
+	require.Nil(t, err, "no error")
+	require.True(t, rl.Allow(), "99th request")
+	require.True(t, rl.Allow(), "100th request")
+	require.False(t, rl.Allow(), "usage reported by the server counts")
+}
@@ -2,11 +2,15 @@ package request
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 )
 
@@ -16,68 +20,114 @@ type HTTPClient interface {
 }
 
 type request struct {
-	client          HTTPClient
-	headers         map[string]string
-	numRetries      int
-	retryInterval   time.Duration
-	retryPolicy     func(*http.Response, error) bool
-	currAttempt     int
-	resultContainer interface{}
-	reasonContainer interface{}
-	body            interface{}
+	client             HTTPClient
+	ctx                context.Context
+	timeout            time.Duration
+	headers            map[string]string
+	pathParams         map[string]string
+	query              url.Values
+	numRetries         int
+	backoff            Backoff
+	maxElapsedTime     time.Duration
+	retryPolicy        RetryPolicy
+	retryNonIdempotent bool
+	currAttempt        int
+	resultContainer    interface{}
+	reasonContainer    interface{}
+	body               interface{}
+	encoder            Encoder
+	decoder            Decoder
+	interceptors       []Interceptor
 }
 
-type response struct {
-	resp     *http.Response
-	err      error
-	hasError bool
+func (r *request) Get(url string) (*Response, error) {
+	return r.Method(http.MethodGet, url)
 }
 
-func (r *response) IsError() bool {
-	return r.hasError
+func (r *request) Post(url string) (*Response, error) {
+	return r.Method(http.MethodPost, url)
 }
 
-func (r *response) StatusCode() int {
-	return r.resp.StatusCode
+func (r *request) Put(url string) (*Response, error) {
+	return r.Method(http.MethodPut, url)
 }
 
-func (r *request) Get(url string) (*response, error) {
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+func (r *request) Patch(url string) (*Response, error) {
+	return r.Method(http.MethodPatch, url)
+}
+
+func (r *request) Delete(url string) (*Response, error) {
+	return r.Method(http.MethodDelete, url)
+}
+
+// Head sends a HEAD request. There is no response body so SetResult has no effect.
+func (r *request) Head(url string) (*Response, error) {
+	return r.Method(http.MethodHead, url)
+}
+
+// Method sends a request with the given verb. A body set with SetBody is encoded and sent
+// with every verb except GET and HEAD. Without one, JSON encoded POST, PUT and PATCH requests
+// send an empty JSON object.
+func (r *request) Method(verb, rawURL string) (*Response, error) {
+	req, cancel, err := r.newHTTPRequest(verb, rawURL)
 	if err != nil {
 		return nil, err
 	}
-	for k, v := range r.headers {
-		req.Header.Set(k, v)
-	}
-	resp, err := r.Do(req)
-	hasError := err != nil
-	return &response{
-		hasError: hasError,
-		resp:     resp,
-		err:      err,
-	}, err
+	defer cancel()
+	res := r.do(req, false)
+	return res, res.err
 }
 
-func (r *request) Post(url string) (*response, error) {
-	if r.body == nil {
-		r.body = `{}`
+// newHTTPRequest builds the request to send. cancel must be called once the call and its
+// response body are done with.
+func (r *request) newHTTPRequest(verb, rawURL string) (*http.Request, context.CancelFunc, error) {
+	verb = strings.ToUpper(verb)
+	body, contentType, err := r.encodeBody(verb)
+	if err != nil {
+		return nil, nil, err
 	}
-	b := new(bytes.Buffer)
-	json.NewEncoder(b).Encode(r.body)
-	req, err := http.NewRequest(http.MethodPost, url, b)
+	u, err := r.buildURL(rawURL)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	ctx, cancel := r.context()
+	req, err := http.NewRequestWithContext(ctx, verb, u, body)
+	if err != nil {
+		cancel()
+		return nil, nil, err
 	}
 	for k, v := range r.headers {
 		req.Header.Set(k, v)
 	}
-	resp, err := r.Do(req)
-	hasError := err != nil
-	return &response{
-		hasError: hasError,
-		resp:     resp,
-		err:      err,
-	}, err
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return req, cancel, nil
+}
+
+// encodeBody encodes the body with the request's encoder, or the codec registered for its
+// Content-Type header
+func (r *request) encodeBody(verb string) (io.Reader, string, error) {
+	if verb == http.MethodGet || verb == http.MethodHead {
+		return nil, "", nil
+	}
+	enc := r.encoder
+	if enc == nil {
+		enc = codecFor(r.headers["Content-Type"])
+	}
+	body := r.body
+	if body == nil {
+		_, isJSON := enc.(JSONCodec)
+		if !isJSON || (verb != http.MethodPost && verb != http.MethodPut && verb != http.MethodPatch) {
+			return nil, "", nil
+		}
+		body = json.RawMessage(`{}`)
+	}
+	encoded, contentType, err := enc.Encode(body)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to encode request body: %v", err)
+	}
+	return encoded, contentType, nil
 }
 
 func R() *request {
@@ -90,11 +140,10 @@ func DefaultR(client HTTPClient) *request {
 		headers: map[string]string{
 			"Content-Type": "application/json",
 		},
-		numRetries:    2,
-		retryInterval: 2 * time.Second,
-		retryPolicy: func(resp *http.Response, err error) bool {
-			return resp.StatusCode >= 500
-		},
+		numRetries:     2,
+		backoff:        ConstantBackoff{Interval: 2 * time.Second},
+		maxElapsedTime: time.Minute,
+		retryPolicy:    DefaultRetryPolicy,
 	}
 }
 
@@ -113,42 +162,227 @@ func (r *request) SetBody(body interface{}) *request {
 	return r
 }
 
+func (r *request) SetHeader(key, value string) *request {
+	if r.headers == nil {
+		r.headers = map[string]string{}
+	}
+	r.headers[key] = value
+	return r
+}
+
+// SetRetries sets how many times a failed call is retried, on top of the first attempt
+func (r *request) SetRetries(n int) *request {
+	r.numRetries = n
+	return r
+}
+
+// SetBackoff sets how long to wait between retries. A Retry-After header on a 429 or 503
+// response takes precedence.
+func (r *request) SetBackoff(b Backoff) *request {
+	r.backoff = b
+	return r
+}
+
+// SetMaxElapsedTime stops retrying once the next retry would start more than d after the
+// first attempt. 0 means retries are only limited by SetRetries.
+func (r *request) SetMaxElapsedTime(d time.Duration) *request {
+	r.maxElapsedTime = d
+	return r
+}
+
+// SetRetryPolicy replaces DefaultRetryPolicy
+func (r *request) SetRetryPolicy(p RetryPolicy) *request {
+	r.retryPolicy = p
+	return r
+}
+
+// SetRetryNonIdempotent allows POST and PATCH requests to be retried even without an
+// Idempotency-Key header. Only do this if the endpoint is safe to call twice.
+func (r *request) SetRetryNonIdempotent(allow bool) *request {
+	r.retryNonIdempotent = allow
+	return r
+}
+
+// SetCodec sets how the body is encoded and the response decoded, regardless of the
+// Content-Type of either
+func (r *request) SetCodec(c Codec) *request {
+	r.encoder = c
+	r.decoder = c
+	return r
+}
+
+func (r *request) SetEncoder(e Encoder) *request {
+	r.encoder = e
+	return r
+}
+
+func (r *request) SetDecoder(d Decoder) *request {
+	r.decoder = d
+	return r
+}
+
+// SetFormBody sends values as an application/x-www-form-urlencoded body
+func (r *request) SetFormBody(values url.Values) *request {
+	r.body = values
+	r.encoder = FormCodec{}
+	return r
+}
+
+// SetMultipartBody sends a multipart/form-data body, e.g. to upload files
+func (r *request) SetMultipartBody(m *Multipart) *request {
+	r.body = m
+	r.encoder = MultipartCodec{}
+	return r
+}
+
+// SetRawBody sends a []byte, string or io.Reader body as is with the given Content-Type
+func (r *request) SetRawBody(body interface{}, contentType string) *request {
+	r.body = body
+	r.encoder = RawCodec{ContentType: contentType}
+	return r
+}
+
+// SetContext sets the context the request is made with. Cancelling it aborts an in-flight
+// call as well as any wait between retries.
+func (r *request) SetContext(ctx context.Context) *request {
+	r.ctx = ctx
+	return r
+}
+
+// SetTimeout bounds the whole call, including retries and the waits between them
+func (r *request) SetTimeout(timeout time.Duration) *request {
+	r.timeout = timeout
+	return r
+}
+
+func (r *request) context() (context.Context, context.CancelFunc) {
+	ctx := r.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if r.timeout > 0 {
+		return context.WithTimeout(ctx, r.timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// Do sends req with the request's retry policy and decodes the response into the result
+// or reason containers
 func (r *request) Do(req *http.Request) (*http.Response, error) {
-	r.currAttempt = 0
-	for r.currAttempt < (r.numRetries + 1) {
-		resp, err := r.client.Do(req)
-		shouldRetry := r.retryPolicy(resp, err)
-		if shouldRetry {
-			r.currAttempt++
-			time.Sleep(r.retryInterval)
+	res := r.do(req, false)
+	if res.err != nil {
+		return nil, res.err
+	}
+	return res.resp, nil
+}
+
+// do sends req, retrying as configured. If stream is set the body of a successful response
+// is left unread for the caller.
+func (r *request) do(req *http.Request, stream bool) *Response {
+	res := &Response{}
+	start := time.Now()
+	var prevWait time.Duration
+	for r.currAttempt = 0; ; r.currAttempt++ {
+		attemptStart := time.Now()
+		resp, err := r.send(req)
+		res.latencies = append(res.latencies, time.Since(attemptStart))
+		if err != nil && req.Context().Err() != nil {
+			return res.finish(start, nil, err) // cancelled or timed out, retrying won't help
+		}
+		if errors.Is(err, ErrCircuitOpen) {
+			return res.finish(start, nil, err) // the dependency is down, fail fast
+		}
+		if r.retryPolicy != nil && r.retryPolicy(resp, err) && r.canRetry(req) {
+			delay, ok := r.nextWait(start, resp, prevWait)
+			if !ok {
+				if err == nil && resp != nil {
+					res.keep(resp)
+					err = newBadStatusError(req, resp, res.body, res.Attempts())
+					_ = r.unmarshal(resp, res.body, r.reasonContainer)
+				}
+				return res.finish(start, resp, retriesExhaustedError{err: err})
+			}
+			discard(resp)
+			if err := wait(req.Context(), delay); err != nil {
+				return res.finish(start, nil, err)
+			}
+			prevWait = delay
 			continue
 		}
 		if err != nil {
-			return nil, err
+			return res.finish(start, nil, err)
 		}
-		body, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return nil, fmt.Errorf("unable to read response body: %v", err)
+		if resp == nil {
+			return res.finish(start, nil, errors.New("no response received"))
+		}
+		if stream && resp.StatusCode < 400 {
+			return res.finish(start, resp, nil) // the caller reads the body
 		}
+		if err := res.keep(resp); err != nil {
+			return res.finish(start, resp, fmt.Errorf("unable to read response body: %v", err))
+		}
+		res.latencies[len(res.latencies)-1] = time.Since(attemptStart)
 		if resp.StatusCode > 399 {
-			e := BadStatusError{code: resp.StatusCode}
-			_ = json.Unmarshal(body, r.reasonContainer)
-			return nil, e
+			e := newBadStatusError(req, resp, res.body, res.Attempts())
+			_ = r.unmarshal(resp, res.body, r.reasonContainer)
+			return res.finish(start, resp, e)
 		}
-		err = json.Unmarshal(body, r.resultContainer)
-		return resp, err
+		err = r.unmarshal(resp, res.body, r.resultContainer)
+		return res.finish(start, resp, err)
 	}
-	return nil, errors.New("max retries exhausted")
 }
 
-type BadStatusError struct {
-	code int
+// nextWait returns how long to wait before the next attempt, or false if there should not
+// be another attempt
+func (r *request) nextWait(start time.Time, resp *http.Response, prev time.Duration) (time.Duration, bool) {
+	if r.currAttempt >= r.numRetries {
+		return 0, false
+	}
+	var delay time.Duration
+	if r.backoff != nil {
+		delay = r.backoff.Next(r.currAttempt+1, prev)
+	}
+	if ra, ok := retryAfter(resp); ok {
+		delay = ra
+	}
+	if r.maxElapsedTime > 0 && time.Since(start)+delay > r.maxElapsedTime {
+		return 0, false
+	}
+	return delay, true
 }
 
-func (e BadStatusError) Error() string {
-	return fmt.Sprintf("bad status code: %v", e.code)
+// discard drains and closes the body of a response that will not be read so the
+// connection can be reused
+func discard(resp *http.Response) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
 }
 
-func (e BadStatusError) Code() int {
-	return e.code
+// unmarshal decodes body into container with the request's decoder, or the codec
+// registered for the response's Content-Type. Empty bodies (e.g. HEAD or 204 responses)
+// and unset containers are skipped.
+func (r *request) unmarshal(resp *http.Response, body []byte, container interface{}) error {
+	if container == nil || len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	dec := r.decoder
+	if dec == nil {
+		dec = codecFor(resp.Header.Get("Content-Type"))
+	}
+	return dec.Decode(body, container)
+}
+
+// wait sleeps for d or until ctx is done, whichever comes first
+func wait(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
 }
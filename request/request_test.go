@@ -2,10 +2,13 @@ package request_test
 
 import (
 	"bytes"
+	"context"
 	"io/ioutil"
 	"net/http"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/serendipity-xyz/common/mocks"
 	"github.com/serendipity-xyz/common/request"
@@ -147,7 +150,7 @@ func TestMaxRetriesExhausted(t *testing.T) {
 	_, err := r.Get("mockURL/v1/path")
 	require.NotNil(t, err, "expected err")
 	require.Equal(t, 3, httpClient.CallCount(), "call count")
-	require.Equal(t, "max retries exhausted", err.Error(), "err check")
+	require.Equal(t, "max retries exhausted: bad status code: 500 (GET mockURL/v1/path): {}", err.Error(), "err check")
 }
 
 func TestSuccessfulPost(t *testing.T) {
@@ -202,11 +205,17 @@ func TestSuccessfulPostRetry(t *testing.T) {
 				ExpectedCalledWith: body,
 				Fuzzy:              true,
 			},
+			{
+				ExpectedMethod:     "POST",
+				ExpectedURLPath:    "mockURL/v1/path",
+				ExpectedCalledWith: body,
+				Fuzzy:              true,
+			},
 		},
 	})
 	var res interface{}
 	var reason interface{}
-	r := request.DefaultR(httpClient).SetResult(&res).SetReason(&reason).SetBody(body)
+	r := request.DefaultR(httpClient).SetResult(&res).SetReason(&reason).SetBody(body).SetRetryNonIdempotent(true)
 	resp, err := r.Post("mockURL/v1/path")
 	require.Nil(t, err, "no error on post expected")
 	require.False(t, resp.IsError(), "expected isError to be false")
@@ -215,3 +224,138 @@ func TestSuccessfulPostRetry(t *testing.T) {
 		"yoohoo": true,
 	}, res, "expected output to be equal")
 }
+
+func TestTimeoutInterruptsRetryWait(t *testing.T) {
+	httpClient := mocks.NewRequestMock(&mocks.NewRequestMockOpts{
+		Responses: []*http.Response{
+			{
+				StatusCode: 500,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{}`))),
+			},
+		},
+	})
+	var res interface{}
+	var reason interface{}
+	r := request.DefaultR(httpClient).SetResult(&res).SetReason(&reason).SetTimeout(50 * time.Millisecond)
+	start := time.Now()
+	_, err := r.Get("mockURL/v1/path")
+	require.ErrorIs(t, err, context.DeadlineExceeded, "deadline exceeded")
+	require.Less(t, time.Since(start), time.Second, "retry wait was interrupted")
+	require.Equal(t, 1, httpClient.CallCount(), "call count")
+}
+
+func TestCancelledContext(t *testing.T) {
+	httpClient := mocks.NewRequestMock(&mocks.NewRequestMockOpts{
+		Responses: []*http.Response{
+			{
+				StatusCode: 500,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{}`))),
+			},
+		},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	var res interface{}
+	_, err := request.DefaultR(httpClient).SetContext(ctx).SetResult(&res).Get("mockURL/v1/path")
+	require.ErrorIs(t, err, context.Canceled, "context cancelled")
+}
+
+func TestVerbs(t *testing.T) {
+	tests := []struct {
+		verb         string
+		body         interface{}
+		expectedBody string
+		respBody     string
+	}{
+		{verb: "PUT", body: map[string]string{"name": "Morning Run"}, expectedBody: `{"name":"Morning Run"}`, respBody: `{"ok": true}`},
+		{verb: "PATCH", respBody: `{"ok": true}`},
+		{verb: "DELETE", respBody: ``},
+		{verb: "HEAD", respBody: ``},
+		{verb: "OPTIONS", respBody: `{"ok": true}`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.verb, func(t *testing.T) {
+			httpClient := mocks.NewRequestMock(&mocks.NewRequestMockOpts{
+				Responses: []*http.Response{
+					{
+						StatusCode: 200,
+						Body:       ioutil.NopCloser(bytes.NewReader([]byte(tc.respBody))),
+					},
+				},
+				Validators: []mocks.RequestValidator{
+					{
+						ExpectedMethod:     tc.verb,
+						ExpectedURLPath:    "mockURL/v1/path",
+						ExpectedCalledWith: tc.expectedBody,
+						Fuzzy:              true,
+					},
+				},
+			})
+			var res map[string]interface{}
+			r := request.DefaultR(httpClient).SetResult(&res).SetBody(tc.body)
+			var resp interface{ IsError() bool }
+			var err error
+			switch tc.verb {
+			case "PUT":
+				resp, err = r.Put("mockURL/v1/path")
+			case "PATCH":
+				resp, err = r.Patch("mockURL/v1/path")
+			case "DELETE":
+				resp, err = r.Delete("mockURL/v1/path")
+			case "HEAD":
+				resp, err = r.Head("mockURL/v1/path")
+			default:
+				resp, err = r.Method(strings.ToLower(tc.verb), "mockURL/v1/path")
+			}
+			require.Nil(t, err, "no error expected")
+			require.False(t, resp.IsError(), "expected isError to be false")
+			require.Equal(t, 1, httpClient.CallCount(), "call count")
+			if tc.respBody != "" {
+				require.Equal(t, map[string]interface{}{"ok": true}, res, "expected output to be equal")
+			}
+		})
+	}
+}
+
+func TestDefaultBody(t *testing.T) {
+	var seen []*http.Request
+	r := request.DefaultR(respondWith("application/json", `{}`, &seen))
+	_, err := r.Post("mockURL/v1/path")
+	require.Nil(t, err, "no error")
+	_, err = r.Put("mockURL/v1/path")
+	require.Nil(t, err, "no error")
+	_, err = r.Patch("mockURL/v1/path")
+	require.Nil(t, err, "no error")
+	_, err = r.Delete("mockURL/v1/path")
+	require.Nil(t, err, "no error")
+	for _, req := range seen[:3] {
+		body, _ := ioutil.ReadAll(req.Body)
+		require.Equal(t, "{}\n", string(body), "%v sends an empty json object", req.Method)
+		require.Equal(t, "application/json", req.Header.Get("Content-Type"), "content type")
+	}
+	require.Nil(t, seen[3].Body, "builder body left unset, DELETE sends none")
+
+	seen = nil
+	_, err = request.DefaultR(respondWith("application/json", `{}`, &seen)).SetCodec(request.FormCodec{}).Post("mockURL/oauth/token")
+	require.Nil(t, err, "non json codecs send no default body")
+	require.Nil(t, seen[0].Body, "no body")
+}
+
+func TestRoutedRetry(t *testing.T) {
+	router := mocks.NewRouter(t)
+	route := router.On("GET", "/v1/athletes/{id}/stats").
+		WithQuery("page", "2").
+		Respond(503, "").
+		RespondJSON(200, map[string]int{"count": 3}).
+		Times(2)
+	var res map[string]int
+	_, err := request.DefaultR(router).SetBackoff(request.ConstantBackoff{}).SetResult(&res).
+		SetPathParam("id", "23").SetQueryParam("page", 2).
+		Get("http://mock/v1/athletes/{id}/stats")
+	require.Nil(t, err, "no error")
+	require.Equal(t, map[string]int{"count": 3}, res, "second response in the sequence")
+	require.Equal(t, 2, route.CallCount(), "retried once")
+}
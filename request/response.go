@@ -0,0 +1,96 @@
+package request
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// Response is the outcome of a call made with the request builder
+type Response struct {
+	resp      *http.Response
+	err       error
+	hasError  bool
+	body      []byte
+	latencies []time.Duration
+	duration  time.Duration
+}
+
+// IsError reports whether the call failed, either with a transport error or a bad status
+func (r *Response) IsError() bool {
+	return r.hasError
+}
+
+// Err returns the error the call failed with, if any
+func (r *Response) Err() error {
+	return r.err
+}
+
+// StatusCode returns the status of the last response received, or 0 if none was
+func (r *Response) StatusCode() int {
+	if r.resp == nil {
+		return 0
+	}
+	return r.resp.StatusCode
+}
+
+// Header returns the headers of the last response received, e.g. to read Strava's
+// X-RateLimit-Usage
+func (r *Response) Header() http.Header {
+	if r.resp == nil {
+		return http.Header{}
+	}
+	return r.resp.Header
+}
+
+// Body returns the raw bytes of the last response received
+func (r *Response) Body() []byte {
+	return r.body
+}
+
+func (r *Response) String() string {
+	return string(r.body)
+}
+
+// Raw returns the last *http.Response received. Its body has already been read but can be
+// read again.
+func (r *Response) Raw() *http.Response {
+	return r.resp
+}
+
+// Attempts returns how many times the request was sent
+func (r *Response) Attempts() int {
+	return len(r.latencies)
+}
+
+// Latencies returns how long each attempt took, from sending the request to reading the
+// body of the final response. Waits between retries are not included.
+func (r *Response) Latencies() []time.Duration {
+	return append([]time.Duration(nil), r.latencies...)
+}
+
+// Duration returns how long the whole call took, including waits between retries
+func (r *Response) Duration() time.Duration {
+	return r.duration
+}
+
+// keep reads and stores the body of resp, replacing it so it can be read again
+func (r *Response) keep(resp *http.Response) error {
+	if resp == nil || resp.Body == nil {
+		return nil
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	r.body = body
+	return err
+}
+
+func (r *Response) finish(start time.Time, resp *http.Response, err error) *Response {
+	r.resp = resp
+	r.err = err
+	r.hasError = err != nil
+	r.duration = time.Since(start)
+	return r
+}
@@ -0,0 +1,59 @@
+package request_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/serendipity-xyz/common/mocks"
+	"github.com/serendipity-xyz/common/request"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseDetails(t *testing.T) {
+	httpClient := mocks.NewRequestMock(&mocks.NewRequestMockOpts{
+		Responses: []*http.Response{
+			{
+				StatusCode: 502,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(`bad gateway`))),
+			},
+			{
+				StatusCode: 200,
+				Header:     http.Header{"X-Ratelimit-Usage": []string{"5,20"}},
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"id": 1}`))),
+			},
+		},
+	})
+	var res map[string]interface{}
+	resp, err := request.DefaultR(httpClient).SetResult(&res).SetBackoff(request.ConstantBackoff{}).Get("mockURL/v1/path")
+	require.Nil(t, err, "no error")
+	require.Equal(t, 200, resp.StatusCode(), "status code")
+	require.Equal(t, "5,20", resp.Header().Get("X-RateLimit-Usage"), "headers exposed")
+	require.Equal(t, `{"id": 1}`, resp.String(), "raw body exposed")
+	require.Equal(t, 2, resp.Attempts(), "attempts")
+	require.Len(t, resp.Latencies(), 2, "one latency per attempt")
+	require.GreaterOrEqual(t, resp.Duration(), resp.Latencies()[0]+resp.Latencies()[1], "duration covers every attempt")
+	body, _ := ioutil.ReadAll(resp.Raw().Body)
+	require.Equal(t, `{"id": 1}`, string(body), "raw response body can be re-read")
+}
+
+func TestErrorResponseDetails(t *testing.T) {
+	httpClient := mocks.NewRequestMock(&mocks.NewRequestMockOpts{
+		Responses: []*http.Response{
+			{
+				StatusCode: 404,
+				Header:     http.Header{"Content-Type": []string{"application/json"}},
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"message": "Record Not Found"}`))),
+			},
+		},
+	})
+	var res interface{}
+	resp, err := request.DefaultR(httpClient).SetResult(&res).Get("mockURL/v1/path")
+	require.NotNil(t, err, "expected an error")
+	require.True(t, resp.IsError(), "is error")
+	require.Equal(t, err, resp.Err(), "error exposed")
+	require.Equal(t, 404, resp.StatusCode(), "status code available on bad status")
+	require.Equal(t, `{"message": "Record Not Found"}`, string(resp.Body()), "body available on bad status")
+	require.Equal(t, 1, resp.Attempts(), "attempts")
+}
@@ -0,0 +1,102 @@
+package request
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+)
+
+// ErrMaxRetriesExhausted is returned once a call has failed on every attempt. The error
+// wraps the last transport error or BadStatusError, so errors.Is and errors.As still work,
+// and includes it in its message.
+var ErrMaxRetriesExhausted = errors.New("max retries exhausted")
+
+type retriesExhaustedError struct {
+	err error
+}
+
+func (e retriesExhaustedError) Error() string {
+	if e.err == nil {
+		return ErrMaxRetriesExhausted.Error()
+	}
+	return ErrMaxRetriesExhausted.Error() + ": " + e.err.Error()
+}
+
+func (e retriesExhaustedError) Is(target error) bool { return target == ErrMaxRetriesExhausted }
+
+func (e retriesExhaustedError) Unwrap() error { return e.err }
+
+// RetryPolicy decides whether a call should be retried. resp is nil whenever err is set.
+// Whatever the policy says, non idempotent requests are only retried if allowed with
+// SetRetryNonIdempotent and requests whose body cannot be rewound are never retried.
+type RetryPolicy func(resp *http.Response, err error) bool
+
+// DefaultRetryPolicy retries transient transport errors (timeouts, refused or reset
+// connections, unexpected EOFs), 429s and 5xxs other than 501
+func DefaultRetryPolicy(resp *http.Response, err error) bool {
+	if err != nil {
+		return IsTransientErr(err)
+	}
+	if resp == nil {
+		return false
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	return resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented
+}
+
+// IsTransientErr reports whether a transport error is worth retrying
+func IsTransientErr(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNABORTED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTemporary || dnsErr.IsTimeout
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// IdempotencyKeyHeader marks a non idempotent request as safe to retry
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get(IdempotencyKeyHeader) != ""
+}
+
+// canRetry checks that req may be sent again and rewinds its body if it has one
+func (r *request) canRetry(req *http.Request) bool {
+	if !r.retryNonIdempotent && !isIdempotent(req) {
+		return false
+	}
+	if req.Body == nil || req.Body == http.NoBody {
+		return true
+	}
+	if req.GetBody == nil {
+		return false
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return false
+	}
+	req.Body = body
+	return true
+}
@@ -0,0 +1,106 @@
+package request_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/serendipity-xyz/common/mocks"
+	"github.com/serendipity-xyz/common/request"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetriesTransportErrors(t *testing.T) {
+	httpClient := mocks.NewRequestMock(&mocks.NewRequestMockOpts{
+		Responses: []*http.Response{
+			nil,
+			{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"hello": "test"}`))),
+			},
+		},
+		Errors: []error{
+			&net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET},
+		},
+	})
+	var res map[string]interface{}
+	_, err := request.DefaultR(httpClient).SetResult(&res).SetBackoff(request.ConstantBackoff{}).Get("mockURL/v1/path")
+	require.Nil(t, err, "no error on get expected")
+	require.Equal(t, 2, httpClient.CallCount(), "call count")
+	require.Equal(t, map[string]interface{}{"hello": "test"}, res, "expected output to be equal")
+}
+
+func TestTransportErrorRetriesExhausted(t *testing.T) {
+	connErr := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	httpClient := mocks.NewRequestMock(&mocks.NewRequestMockOpts{
+		Responses: []*http.Response{nil, nil, nil},
+		Errors:    []error{connErr, connErr, connErr},
+	})
+	var res interface{}
+	_, err := request.DefaultR(httpClient).SetResult(&res).SetBackoff(request.ConstantBackoff{}).Get("mockURL/v1/path")
+	require.ErrorIs(t, err, request.ErrMaxRetriesExhausted, "retries exhausted")
+	require.ErrorIs(t, err, syscall.ECONNREFUSED, "wraps the last transport error")
+	require.Equal(t, 3, httpClient.CallCount(), "call count")
+}
+
+func TestDoesNotRetryPermanentErrors(t *testing.T) {
+	httpClient := mocks.NewRequestMock(&mocks.NewRequestMockOpts{
+		Responses: []*http.Response{nil},
+		Errors:    []error{errors.New(`unsupported protocol scheme "mock"`)},
+	})
+	var res interface{}
+	_, err := request.DefaultR(httpClient).SetResult(&res).Get("mock://v1/path")
+	require.EqualError(t, err, `unsupported protocol scheme "mock"`, "error returned as is")
+	require.Equal(t, 1, httpClient.CallCount(), "call count")
+}
+
+func TestDoesNotRetryPostByDefault(t *testing.T) {
+	httpClient := mocks.NewRequestMock(&mocks.NewRequestMockOpts{
+		Responses: []*http.Response{
+			{
+				StatusCode: 500,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"error": "boom"}`))),
+			},
+		},
+	})
+	var res interface{}
+	var reason map[string]interface{}
+	_, err := request.DefaultR(httpClient).SetResult(&res).SetReason(&reason).SetBody("test").Post("mockURL/v1/path")
+	require.IsType(t, request.BadStatusError{}, err, "expected error type")
+	require.Equal(t, 1, httpClient.CallCount(), "call count")
+	require.Equal(t, map[string]interface{}{"error": "boom"}, reason, "reason decoded")
+}
+
+func TestRetriesPostWithIdempotencyKey(t *testing.T) {
+	httpClient := mocks.NewRequestMock(&mocks.NewRequestMockOpts{
+		Responses: []*http.Response{
+			{StatusCode: 503, Body: ioutil.NopCloser(bytes.NewReader([]byte(``)))},
+			{StatusCode: 200, Body: ioutil.NopCloser(bytes.NewReader([]byte(`{}`)))},
+		},
+		Validators: []mocks.RequestValidator{
+			{ExpectedMethod: "POST", ExpectedCalledWith: `{"name":"run"}`, Fuzzy: true},
+			{ExpectedMethod: "POST", ExpectedCalledWith: `{"name":"run"}`, Fuzzy: true},
+		},
+	})
+	var res interface{}
+	_, err := request.DefaultR(httpClient).
+		SetResult(&res).
+		SetBody(map[string]string{"name": "run"}).
+		SetHeader(request.IdempotencyKeyHeader, "mockKey").
+		SetBackoff(request.ConstantBackoff{Interval: time.Millisecond}).
+		Post("mockURL/v1/path")
+	require.Nil(t, err, "no error")
+	require.Equal(t, 2, httpClient.CallCount(), "body rewound and re-sent")
+}
+
+func TestNilResponseDoesNotPanic(t *testing.T) {
+	httpClient := mocks.NewRequestMock(&mocks.NewRequestMockOpts{})
+	var res interface{}
+	_, err := request.DefaultR(httpClient).SetResult(&res).Get("mockURL/v1/path")
+	require.NotNil(t, err, "expected an error")
+}
@@ -0,0 +1,156 @@
+package request
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Stream is the unread body of a successful response, for payloads too large to buffer
+// such as activity streams or GPX exports. Close it to release the connection.
+type Stream struct {
+	body   io.ReadCloser
+	res    *Response
+	cancel context.CancelFunc
+}
+
+// Stream sends the request like Method but leaves the body of a successful response for
+// the caller to read. Retries and status handling work as usual: a bad status comes back as
+// a BadStatusError, with the reason container filled in, and no stream. The result
+// container and codecs are not used. A timeout set with SetTimeout keeps running until the
+// stream is closed.
+func (r *request) Stream(verb, rawURL string) (*Stream, error) {
+	req, cancel, err := r.newHTTPRequest(verb, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	res := r.do(req, true)
+	if res.err != nil {
+		cancel()
+		return nil, res.err
+	}
+	body := res.resp.Body
+	if body == nil {
+		body = http.NoBody
+	}
+	return &Stream{body: body, res: res, cancel: cancel}, nil
+}
+
+func (s *Stream) Read(p []byte) (int, error) {
+	return s.body.Read(p)
+}
+
+func (s *Stream) Close() error {
+	err := s.body.Close()
+	s.cancel()
+	return err
+}
+
+// Response returns the status, headers and attempts of the call. Its Body is empty, the
+// body is only available by reading the stream.
+func (s *Stream) Response() *Response {
+	return s.res
+}
+
+// JSON decodes the stream one value at a time. Closing the iterator closes the stream.
+func (s *Stream) JSON() *JSONIterator {
+	return NewJSONIterator(s)
+}
+
+// JSONIterator decodes the elements of a JSON array, or a sequence of JSON values such as
+// newline delimited JSON, one at a time without reading the whole input into memory.
+//
+//	it := stream.JSON()
+//	defer it.Close()
+//	var point StreamPoint
+//	for it.Next(&point) {
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type JSONIterator struct {
+	r       *bufio.Reader
+	closer  io.Closer
+	dec     *json.Decoder
+	array   bool
+	started bool
+	done    bool
+	err     error
+}
+
+// NewJSONIterator iterates over r, closing it on Close if it is an io.Closer
+func NewJSONIterator(r io.Reader) *JSONIterator {
+	it := &JSONIterator{r: bufio.NewReader(r)}
+	if c, ok := r.(io.Closer); ok {
+		it.closer = c
+	}
+	return it
+}
+
+// Next decodes the next value into v, returning false once there are none left or
+// decoding fails
+func (it *JSONIterator) Next(v interface{}) bool {
+	if !it.started {
+		it.start()
+	}
+	if it.done || it.err != nil {
+		return false
+	}
+	if !it.dec.More() {
+		it.done = true
+		if it.array {
+			if _, err := it.dec.Token(); err != nil {
+				it.err = fmt.Errorf("unable to read end of JSON array: %v", err)
+			}
+		}
+		return false
+	}
+	if err := it.dec.Decode(v); err != nil {
+		it.err = err
+		return false
+	}
+	return true
+}
+
+// start checks whether the input is an array or a sequence of values
+func (it *JSONIterator) start() {
+	it.started = true
+	for {
+		b, err := it.r.ReadByte()
+		if err == io.EOF {
+			it.done = true
+			return
+		}
+		if err != nil {
+			it.err = err
+			return
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		it.r.UnreadByte()
+		it.array = b == '['
+		break
+	}
+	it.dec = json.NewDecoder(it.r)
+	if it.array {
+		it.dec.Token() // the opening bracket, already checked
+	}
+}
+
+// Err returns the error that stopped iteration, if any
+func (it *JSONIterator) Err() error {
+	return it.err
+}
+
+func (it *JSONIterator) Close() error {
+	if it.closer == nil {
+		return nil
+	}
+	return it.closer.Close()
+}
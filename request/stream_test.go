@@ -0,0 +1,121 @@
+package request_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/serendipity-xyz/common/mocks"
+	"github.com/serendipity-xyz/common/request"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStream(t *testing.T) {
+	httpClient := mocks.NewRequestMock(&mocks.NewRequestMockOpts{
+		Responses: []*http.Response{
+			{StatusCode: 502, Body: ioutil.NopCloser(strings.NewReader("bad gateway"))},
+			{
+				StatusCode: 200,
+				Header:     http.Header{"Content-Type": []string{"application/gpx+xml"}},
+				Body:       ioutil.NopCloser(strings.NewReader("<gpx></gpx>")),
+			},
+		},
+	})
+	stream, err := request.DefaultR(httpClient).SetBackoff(request.ConstantBackoff{}).Stream(http.MethodGet, "http://mock/v1/export_gpx")
+	require.Nil(t, err, "no error")
+	defer stream.Close()
+	require.Equal(t, 200, stream.Response().StatusCode(), "status")
+	require.Equal(t, "application/gpx+xml", stream.Response().Header().Get("Content-Type"), "headers")
+	require.Equal(t, 2, stream.Response().Attempts(), "retried as usual")
+	body, err := ioutil.ReadAll(stream)
+	require.Nil(t, err, "no read error")
+	require.Equal(t, "<gpx></gpx>", string(body), "body streamed")
+}
+
+func TestStreamBadStatus(t *testing.T) {
+	var reason map[string]interface{}
+	_, err := request.DefaultR(statusClient(404, `{"message": "Record Not Found"}`)).SetReason(&reason).
+		Stream(http.MethodGet, "http://mock/v1/path")
+	require.True(t, errors.Is(err, request.ErrNotFound), "status error handling applies")
+	require.Equal(t, map[string]interface{}{"message": "Record Not Found"}, reason, "reason decoded")
+}
+
+func TestStreamCloseCancelsContext(t *testing.T) {
+	var seen []*http.Request
+	stream, err := request.DefaultR(okClient(&seen)).Stream(http.MethodGet, "http://mock/v1/path")
+	require.Nil(t, err, "no error")
+	require.Nil(t, seen[0].Context().Err(), "context alive while streaming")
+	require.Nil(t, stream.Close(), "close")
+	require.Equal(t, context.Canceled, seen[0].Context().Err(), "context released on close")
+}
+
+func TestStreamThenMethodOnSameBuilder(t *testing.T) {
+	httpClient := mocks.NewRequestMock(&mocks.NewRequestMockOpts{
+		Responses: []*http.Response{
+			{StatusCode: 200, Body: ioutil.NopCloser(strings.NewReader("<gpx></gpx>"))},
+			{StatusCode: 200, Body: ioutil.NopCloser(strings.NewReader(`{"id": 1}`))},
+		},
+	})
+	var res map[string]interface{}
+	r := request.DefaultR(httpClient).SetResult(&res)
+	stream, err := r.Stream(http.MethodGet, "http://mock/v1/export_gpx")
+	require.Nil(t, err, "no error streaming")
+	require.Nil(t, stream.Close(), "close")
+	resp, err := r.Get("http://mock/v1/activity")
+	require.Nil(t, err, "no error")
+	require.Equal(t, `{"id": 1}`, string(resp.Body()), "body read as usual")
+	require.Equal(t, map[string]interface{}{"id": float64(1)}, res, "result decoded")
+}
+
+type point struct {
+	Time     int     `json:"time"`
+	Distance float64 `json:"distance"`
+}
+
+func TestJSONIterator(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []point
+	}{
+		{"array", ` [{"time": 0, "distance": 0}, {"time": 1, "distance": 2.5}] `, []point{{0, 0}, {1, 2.5}}},
+		{"newline delimited", "{\"time\": 0}\n{\"time\": 1}\n", []point{{Time: 0}, {Time: 1}}},
+		{"empty array", `[]`, nil},
+		{"empty input", ``, nil},
+	}
+	for _, tt := range tests {
+		it := request.NewJSONIterator(strings.NewReader(tt.input))
+		var got []point
+		var p point
+		for it.Next(&p) {
+			got = append(got, p)
+			p = point{}
+		}
+		require.Nil(t, it.Err(), "%v: no error", tt.name)
+		require.Equal(t, tt.want, got, "%v: decoded values", tt.name)
+	}
+
+	it := request.NewJSONIterator(strings.NewReader(`[{"time": 0}, {"time": `))
+	var p point
+	require.True(t, it.Next(&p), "first value decoded")
+	require.False(t, it.Next(&p), "truncated value")
+	require.NotNil(t, it.Err(), "error reported")
+}
+
+func TestStreamJSON(t *testing.T) {
+	stream, err := request.DefaultR(respondWith("application/json", `[{"time": 0}, {"time": 1}, {"time": 2}]`, new([]*http.Request))).
+		Stream(http.MethodGet, "http://mock/v1/activities/1/streams")
+	require.Nil(t, err, "no error")
+	it := stream.JSON()
+	defer it.Close()
+	var times []int
+	var p point
+	for it.Next(&p) {
+		times = append(times, p.Time)
+	}
+	require.Nil(t, it.Err(), "no error")
+	require.Equal(t, []int{0, 1, 2}, times, "decoded as it goes")
+}
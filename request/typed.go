@@ -0,0 +1,50 @@
+package request
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// ReasonError is returned by Fetch and the typed helpers when the response has a bad
+// status. It carries the decoded error body and wraps the BadStatusError, so errors.Is and
+// errors.As work as they do on any other call.
+type ReasonError[R any] struct {
+	Reason R
+	err    error
+}
+
+func (e ReasonError[R]) Error() string {
+	return e.err.Error()
+}
+
+func (e ReasonError[R]) Unwrap() error {
+	return e.err
+}
+
+// Fetch sends a request built with R or DefaultR and decodes a successful response into a
+// T and a bad status response into the Reason of a ReasonError[R]. Any result or reason
+// container already set on r is replaced.
+//
+//	activity, _, err := request.Fetch[Activity, Fault](request.DefaultR(client).SetContext(ctx), http.MethodGet, url)
+func Fetch[T, R any](r *request, verb, rawURL string) (T, *Response, error) {
+	var result T
+	var reason R
+	res, err := r.SetResult(&result).SetReason(&reason).Method(verb, rawURL)
+	var bse BadStatusError
+	if errors.As(err, &bse) {
+		return result, res, ReasonError[R]{Reason: reason, err: err}
+	}
+	return result, res, err
+}
+
+// GetJSON gets url with DefaultR and decodes the response into a T. A bad status error
+// carries the error body decoded as a map, see ReasonError.
+func GetJSON[T any](ctx context.Context, client HTTPClient, url string) (T, *Response, error) {
+	return Fetch[T, map[string]interface{}](DefaultR(client).SetContext(ctx), http.MethodGet, url)
+}
+
+// PostJSON posts body as JSON with DefaultR and decodes the response into a T
+func PostJSON[T any](ctx context.Context, client HTTPClient, url string, body interface{}) (T, *Response, error) {
+	return Fetch[T, map[string]interface{}](DefaultR(client).SetContext(ctx).SetBody(body), http.MethodPost, url)
+}
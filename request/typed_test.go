@@ -0,0 +1,66 @@
+package request_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/serendipity-xyz/common/request"
+	"github.com/stretchr/testify/require"
+)
+
+type fault struct {
+	Message string `json:"message"`
+}
+
+func TestFetch(t *testing.T) {
+	var seen []*http.Request
+	type activity struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	client := respondWith("application/json", `{"id": 1, "name": "Morning Run"}`, &seen)
+	got, resp, err := request.Fetch[activity, fault](request.DefaultR(client), http.MethodGet, "http://mock/v1/activities/1")
+	require.Nil(t, err, "no error")
+	require.Equal(t, activity{ID: 1, Name: "Morning Run"}, got, "typed result")
+	require.Equal(t, 200, resp.StatusCode(), "response returned")
+}
+
+func TestFetchReason(t *testing.T) {
+	_, _, err := request.Fetch[map[string]int, fault](request.DefaultR(statusClient(404, `{"message": "Record Not Found"}`)), http.MethodGet, "http://mock/v1/activities/1")
+	var re request.ReasonError[fault]
+	require.True(t, errors.As(err, &re), "reason error")
+	require.Equal(t, "Record Not Found", re.Reason.Message, "typed reason")
+	require.True(t, errors.Is(err, request.ErrNotFound), "category still matches")
+	var bse request.BadStatusError
+	require.True(t, errors.As(err, &bse), "bad status error still available")
+	require.Equal(t, 404, bse.Code(), "code")
+}
+
+func TestFetchReasonAfterRetries(t *testing.T) {
+	r := request.DefaultR(statusClient(503, `{"message": "down"}`)).SetBackoff(request.ConstantBackoff{})
+	_, _, err := request.Fetch[map[string]int, fault](r, http.MethodGet, "http://mock/v1/path")
+	var re request.ReasonError[fault]
+	require.True(t, errors.As(err, &re), "reason error")
+	require.Equal(t, "down", re.Reason.Message, "reason decoded from the last response")
+	require.True(t, errors.Is(err, request.ErrMaxRetriesExhausted), "retries exhausted")
+}
+
+func TestGetAndPostJSON(t *testing.T) {
+	var seen []*http.Request
+	client := respondWith("application/json", `[1, 2, 3]`, &seen)
+	got, _, err := request.GetJSON[[]int](context.Background(), client, "http://mock/v1/path")
+	require.Nil(t, err, "no error")
+	require.Equal(t, []int{1, 2, 3}, got, "typed result")
+
+	posted, _, err := request.PostJSON[[]int](context.Background(), client, "http://mock/v1/path", map[string]int{"a": 1})
+	require.Nil(t, err, "no error")
+	require.Equal(t, []int{1, 2, 3}, posted, "typed result")
+	require.Equal(t, http.MethodPost, seen[1].Method, "posted")
+
+	_, _, err = request.GetJSON[[]int](context.Background(), statusClient(400, `{"error": "bad"}`), "http://mock/v1/path")
+	var re request.ReasonError[map[string]interface{}]
+	require.True(t, errors.As(err, &re), "reason error")
+	require.Equal(t, "bad", re.Reason["error"], "reason decoded as a map")
+}
@@ -0,0 +1,120 @@
+package request
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var pathParamPattern = regexp.MustCompile(`\{([A-Za-z0-9_\-]+)\}`)
+
+// SetPathParam fills in a {key} placeholder in the url, e.g. "/activities/{id}". The value
+// is path escaped.
+func (r *request) SetPathParam(key, value string) *request {
+	if r.pathParams == nil {
+		r.pathParams = map[string]string{}
+	}
+	r.pathParams[key] = value
+	return r
+}
+
+func (r *request) SetPathParams(params map[string]string) *request {
+	for k, v := range params {
+		r.SetPathParam(k, v)
+	}
+	return r
+}
+
+// SetQueryParam sets a query parameter, replacing any value already in the url. Strings,
+// bools, ints, uints, floats and fmt.Stringers are formatted as you'd expect, a time.Time
+// is sent as a unix timestamp in seconds and a []string adds the key once per element.
+func (r *request) SetQueryParam(key string, value interface{}) *request {
+	if r.query == nil {
+		r.query = url.Values{}
+	}
+	r.query[key] = queryValues(value)
+	return r
+}
+
+// AddQueryParam is like SetQueryParam but keeps values already set for key
+func (r *request) AddQueryParam(key string, value interface{}) *request {
+	if r.query == nil {
+		r.query = url.Values{}
+	}
+	r.query[key] = append(r.query[key], queryValues(value)...)
+	return r
+}
+
+func (r *request) SetQueryParams(params url.Values) *request {
+	for k, v := range params {
+		r.SetQueryParam(k, v)
+	}
+	return r
+}
+
+func queryValues(value interface{}) []string {
+	switch v := value.(type) {
+	case nil:
+		return []string{""}
+	case string:
+		return []string{v}
+	case []string:
+		return append([]string(nil), v...)
+	case bool:
+		return []string{strconv.FormatBool(v)}
+	case int:
+		return []string{strconv.Itoa(v)}
+	case int32:
+		return []string{strconv.FormatInt(int64(v), 10)}
+	case int64:
+		return []string{strconv.FormatInt(v, 10)}
+	case uint:
+		return []string{strconv.FormatUint(uint64(v), 10)}
+	case uint32:
+		return []string{strconv.FormatUint(uint64(v), 10)}
+	case uint64:
+		return []string{strconv.FormatUint(v, 10)}
+	case float32:
+		return []string{strconv.FormatFloat(float64(v), 'f', -1, 32)}
+	case float64:
+		return []string{strconv.FormatFloat(v, 'f', -1, 64)}
+	case time.Time:
+		return []string{strconv.FormatInt(v.Unix(), 10)}
+	case fmt.Stringer:
+		return []string{v.String()}
+	}
+	return []string{fmt.Sprintf("%v", value)}
+}
+
+// buildURL fills in path params and merges query params into rawURL
+func (r *request) buildURL(rawURL string) (string, error) {
+	var missing []string
+	filled := pathParamPattern.ReplaceAllStringFunc(rawURL, func(m string) string {
+		key := m[1 : len(m)-1]
+		v, ok := r.pathParams[key]
+		if !ok {
+			missing = append(missing, key)
+			return m
+		}
+		return url.PathEscape(v)
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("missing path parameter(s): %v", strings.Join(missing, ", "))
+	}
+	if len(r.query) == 0 {
+		return filled, nil
+	}
+	u, err := url.Parse(filled)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	for k, v := range r.query {
+		q[k] = v
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
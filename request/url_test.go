@@ -0,0 +1,60 @@
+package request_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/serendipity-xyz/common/request"
+	"github.com/stretchr/testify/require"
+)
+
+type clientFunc func(*http.Request) (*http.Response, error)
+
+func (f clientFunc) Do(req *http.Request) (*http.Response, error) { return f(req) }
+
+func okClient(seen *[]*http.Request) clientFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		*seen = append(*seen, req)
+		return &http.Response{
+			StatusCode: 200,
+			Header:     http.Header{},
+			Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{}`))),
+		}, nil
+	}
+}
+
+func TestPathAndQueryParams(t *testing.T) {
+	var seen []*http.Request
+	var res interface{}
+	_, err := request.DefaultR(okClient(&seen)).
+		SetResult(&res).
+		SetPathParam("id", "12/34").
+		SetQueryParam("access_token", "a&b=c").
+		SetQueryParam("page", 2).
+		SetQueryParam("include_all_efforts", true).
+		SetQueryParam("after", time.Unix(1665964800, 0)).
+		AddQueryParam("scope", "read").
+		AddQueryParam("scope", "activity:read").
+		Get("https://www.strava.com/api/v3/activities/{id}?per_page=30")
+	require.Nil(t, err, "no error")
+	require.Len(t, seen, 1, "call count")
+	u := seen[0].URL
+	require.Equal(t, "/api/v3/activities/12%2F34", u.EscapedPath(), "path param is escaped")
+	q := u.Query()
+	require.Equal(t, "a&b=c", q.Get("access_token"), "query param round trips")
+	require.Equal(t, "2", q.Get("page"), "int")
+	require.Equal(t, "true", q.Get("include_all_efforts"), "bool")
+	require.Equal(t, "1665964800", q.Get("after"), "time as unix seconds")
+	require.Equal(t, []string{"read", "activity:read"}, q["scope"], "repeated values")
+	require.Equal(t, "30", q.Get("per_page"), "existing query kept")
+}
+
+func TestMissingPathParam(t *testing.T) {
+	var seen []*http.Request
+	_, err := request.DefaultR(okClient(&seen)).Get("https://www.strava.com/api/v3/activities/{id}/streams/{type}")
+	require.EqualError(t, err, "missing path parameter(s): id, type", "error")
+	require.Len(t, seen, 0, "nothing sent")
+}
@@ -1,6 +1,7 @@
 package sqs
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"os"
@@ -132,6 +133,16 @@ func (m Msg) String() (string, error) {
 	return string(res), nil
 }
 
+// Context returns a copy of parent carrying the message's messageId as its transaction ID
+// so loggers derived with log.FromContext tag every line with it
+func (m Msg) Context(parent context.Context) context.Context {
+	id, ok := m["messageId"].(string)
+	if !ok {
+		return parent
+	}
+	return log.ContextWithTransactionID(parent, id)
+}
+
 func (p *Producer) ProduceMsg(msg Msg) (string, error) {
 	s, err := msg.String()
 	if err != nil {
@@ -154,16 +165,19 @@ type Consumer struct {
 
 func (c *Consumer) MsgChan() chan *Msg { return c.msgChan }
 
+// Poll receives messages forever and pushes them onto MsgChan. Its polling log lines are
+// rate limited since they repeat every poll; messages that can't be decoded are always logged.
 func (c *Consumer) Poll(l log.Logger) {
+	pl := log.NewRateLimitedLogger(l, nil)
 	for {
-		l.Info("polling message queue [%v]....", c.client.queueName)
+		pl.Info("polling message queue [%v]....", c.client.queueName)
 		output, err := c.client.sqsClient.ReceiveMessage(&sqs.ReceiveMessageInput{
 			QueueUrl:            c.client.queueURL,
 			MaxNumberOfMessages: aws.Int64(1),
 			WaitTimeSeconds:     aws.Int64(15),
 		})
 		if err != nil {
-			l.Error("failed to fetch sqs message %v", err)
+			pl.Error("failed to fetch sqs message %v", err)
 			continue
 		}
 		for _, sqsMsg := range output.Messages {
@@ -172,7 +186,7 @@ func (c *Consumer) Poll(l log.Logger) {
 				"receiptHandle": *sqsMsg.ReceiptHandle,
 			}
 			if err := json.Unmarshal([]byte(*sqsMsg.Body), &msg); err != nil {
-				l.Error("failed to fetch sqs message %v", err)
+				l.Error("unable to decode sqs message [%v]: %v", *sqsMsg.MessageId, err)
 				continue
 			}
 			c.msgChan <- msg
@@ -1,8 +1,10 @@
 package sqs_test
 
 import (
+	"context"
 	"testing"
 
+	"github.com/serendipity-xyz/common/log"
 	"github.com/serendipity-xyz/common/sqs"
 	"github.com/stretchr/testify/require"
 )
@@ -16,3 +18,14 @@ func TestInit(t *testing.T) {
 	})
 	require.NotNil(t, err, "there should be an err if no access key")
 }
+
+func TestMsgContext(t *testing.T) {
+	msg := sqs.Msg{"messageId": "mockMessageId"}
+	ctx := msg.Context(context.Background())
+	require.Equal(t, "mockMessageId", log.TransactionIDFromContext(ctx), "transaction id")
+	l := log.FromContext(ctx)
+	require.Equal(t, log.StdOutLogger{TransactionID: "mockMessageId"}, l, "logger is tagged")
+
+	ctx = sqs.Msg{}.Context(context.Background())
+	require.Equal(t, "", log.TransactionIDFromContext(ctx), "no transaction id without a messageId")
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"github.com/serendipity-xyz/common/log"
+	"github.com/serendipity-xyz/common/request"
+)
+
+type HTTPCacheParams struct {
+	Collection string
+	Logger     log.Logger // storage errors are logged as warnings, defaults to StdOutLogger
+}
+
+// HTTPCache is a request.Cache that persists responses with a Manager, e.g. in a mongo
+// collection, so they survive restarts and are shared between instances. Documents are
+// keyed by the hashed request.CacheKey in a "key" field, which should be indexed.
+type HTTPCache struct {
+	m          Manager
+	collection string
+	l          log.Logger
+}
+
+func NewHTTPCache(m Manager, params *HTTPCacheParams) *HTTPCache {
+	c := &HTTPCache{m: m, collection: params.Collection, l: params.Logger}
+	if c.l == nil {
+		c.l = log.StdOutLogger{}
+	}
+	return c
+}
+
+func (c *HTTPCache) Get(key string) (*request.CachedResponse, bool) {
+	cc := NewCallContext()
+	defer cc.Cancel()
+	d, err := c.m.FindOne(c.l, cc, &FindOneParams{
+		Collection: c.collection,
+		Filter:     map[string]interface{}{"key": key},
+	})
+	if IsNotFoundErr(err) {
+		return nil, false
+	}
+	if err != nil {
+		c.l.Warn("unable to read cached response: %v", err)
+		return nil, false
+	}
+	var cr request.CachedResponse
+	if err := d.Decode(&cr); err != nil {
+		c.l.Warn("unable to decode cached response: %v", err)
+		return nil, false
+	}
+	return &cr, true
+}
+
+func (c *HTTPCache) Set(key string, resp *request.CachedResponse) {
+	cc := NewCallContext()
+	defer cc.Cancel()
+	_, err := c.m.Upsert(c.l, cc, resp, &UpsertParams{
+		Collection: c.collection,
+		Filter:     map[string]interface{}{"key": key},
+		Generic:    true,
+	})
+	if err != nil {
+		c.l.Warn("unable to cache response: %v", err)
+	}
+}
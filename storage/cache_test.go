@@ -0,0 +1,36 @@
+package storage_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/serendipity-xyz/common/mocks"
+	"github.com/serendipity-xyz/common/request"
+	"github.com/serendipity-xyz/common/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPCache(t *testing.T) {
+	stored := request.CachedResponse{
+		Key:        "abc",
+		StatusCode: 200,
+		Header:     http.Header{"Etag": []string{`"v1"`}},
+		Body:       []byte(`{"id": 1}`),
+		ETag:       `"v1"`,
+	}
+	db := &mocks.MockDBManager{
+		Responses:    []interface{}{nil, int64(1), stored},
+		Errors:       []interface{}{storage.NotFoundError{}},
+		FilterChecks: []interface{}{map[string]interface{}{"key": "abc"}, map[string]interface{}{"key": "abc"}, map[string]interface{}{"key": "abc"}},
+	}
+	l := mocks.NewRecordingLogger()
+	c := storage.NewHTTPCache(db, &storage.HTTPCacheParams{Collection: "httpcache", Logger: l})
+	_, ok := c.Get("abc")
+	require.False(t, ok, "miss")
+	c.Set("abc", &stored)
+	got, ok := c.Get("abc")
+	require.True(t, ok, "hit")
+	require.Equal(t, stored.Body, got.Body, "body")
+	require.Equal(t, `"v1"`, got.ETag, "etag")
+	require.Empty(t, l.Entries(), "nothing logged")
+}
@@ -39,6 +39,12 @@ func NewCallContext() *CallContext {
 	return &CallContext{ctx: ctx, cancel: cancel}
 }
 
+// Cancel releases the call context's resources once the call, and any decoding of its
+// result, is done
+func (cc *CallContext) Cancel() {
+	cc.cancel()
+}
+
 // NewMongoClient returns a new mongoDB client
 func NewMongoClient(client *mongo.Client, database *mongo.Database) *mongoClient {
 	return &mongoClient{
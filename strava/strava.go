@@ -1,9 +1,11 @@
 package strava
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"net/http"
-	"strings"
+	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/serendipity-xyz/common/log"
@@ -43,6 +45,20 @@ type CallContextalizer interface {
 	L() log.Logger
 }
 
+// Contexter is implemented by CallContextalizers that carry a context.Context, e.g. one
+// derived from an incoming http request. Its deadline and cancellation apply to the calls
+// the client makes to strava.
+type Contexter interface {
+	Context() context.Context
+}
+
+func contextOf(cc CallContextalizer) context.Context {
+	if c, ok := cc.(Contexter); ok && c.Context() != nil {
+		return c.Context()
+	}
+	return context.Background()
+}
+
 type TokenManager interface {
 	SetUserTokens(cc CallContextalizer, userID string, tokens Tokens) error
 }
@@ -58,6 +74,7 @@ type Client struct {
 	refreshToken string
 	expiresAt    int
 	tokenManager TokenManager
+	interceptors []request.Interceptor
 }
 
 type ClientParams struct {
@@ -78,6 +95,7 @@ func NewClient(userID string, tokens Tokens, tokenManager TokenManager, params *
 		expiresAt:    tokens.ExpiresAt,
 		tokenManager: tokenManager,
 		httpClient:   httpClient,
+		interceptors: []request.Interceptor{request.TransactionIDInterceptor()},
 	}
 }
 
@@ -85,6 +103,36 @@ func (sc *Client) SetClient(client request.HTTPClient) {
 	sc.httpClient = client
 }
 
+// Use adds interceptors to every call the client makes to strava, e.g.
+// request.LoggingInterceptor
+func (sc *Client) Use(interceptors ...request.Interceptor) {
+	sc.interceptors = append(sc.interceptors, interceptors...)
+}
+
+// bearerAuth authenticates api calls with the current access token, which changes when
+// it is refreshed
+func (sc *Client) bearerAuth() request.Interceptor {
+	return request.BearerAuthFunc(func(*http.Request) (string, error) {
+		return sc.accessToken, nil
+	})
+}
+
+// NewRateLimiter returns a limiter for strava's default per app limits of 100 requests
+// every 15 minutes and 1000 a day. It calibrates itself from the X-RateLimit headers on
+// strava's responses. Share one between every Client and add it with
+// sc.Use(rl.Interceptor()).
+func NewRateLimiter(failFast bool) *request.RateLimiter {
+	return request.NewRateLimiter(&request.RateLimiterParams{
+		Limits: []request.Limit{
+			{Requests: 100, Window: 15 * time.Minute},
+			{Requests: 1000, Window: 24 * time.Hour},
+		},
+		FailFast:    failFast,
+		LimitHeader: "X-RateLimit-Limit",
+		UsageHeader: "X-RateLimit-Usage",
+	})
+}
+
 // AuthorizationURL returns the redirect URL for strava to authenticate a user
 func (sc *Client) AuthorizationURL(scope string, device Device) string {
 	base := stravaWebAuthURI
@@ -95,7 +143,13 @@ func (sc *Client) AuthorizationURL(scope string, device Device) string {
 	} else if device == Mobile {
 		base = stravaAndroidAuthURI
 	}
-	return fmt.Sprintf("%v?client_id=%v&response_type=code&redirect_uri=%v&approval_prompt=auto&scope=%v", base, sc.clientID, sc.redirectURI, scope)
+	return base + "?" + url.Values{
+		"client_id":       {sc.clientID},
+		"response_type":   {"code"},
+		"redirect_uri":    {sc.redirectURI},
+		"approval_prompt": {"auto"},
+		"scope":           {scope},
+	}.Encode()
 }
 
 type Athlete struct {
@@ -122,20 +176,29 @@ type TokenResponse struct {
 // GenerateTokens is used when a user is authenticating via strava. Strava will redirect them to
 // our app with a code in the query parameters that allows us to generate new tokens.
 func (sc *Client) GenerateTokens(l log.Logger, code string) (TokenResponse, error) {
-	url := fmt.Sprintf("%v/oauth/token?client_id=%v&client_secret=%v&code=%v&grant_type=authorization_code", stravaAPIBaseURL, sc.clientID, sc.clientSecret, code)
+	return sc.generateTokens(context.Background(), l, code)
+}
+
+// GenerateTokensContext is GenerateTokens for a call context. If cc is a Contexter its
+// context bounds the call to strava, as with ListActivities and GetActivity.
+func (sc *Client) GenerateTokensContext(cc CallContextalizer, code string) (TokenResponse, error) {
+	return sc.generateTokens(contextOf(cc), cc.L(), code)
+}
+
+func (sc *Client) generateTokens(ctx context.Context, l log.Logger, code string) (TokenResponse, error) {
 	var result TokenResponse
-	var reason interface{}
-	r := request.DefaultR(sc.httpClient).SetResult(&result).SetReason(&reason)
-	resp, err := r.Post(url)
+	r := request.DefaultR(sc.httpClient).SetContext(ctx).Use(sc.interceptors...).SetResult(&result).
+		SetFormBody(url.Values{
+			"client_id":     {sc.clientID},
+			"client_secret": {sc.clientSecret},
+			"code":          {code},
+			"grant_type":    {"authorization_code"},
+		})
+	_, err := r.Post(stravaAPIBaseURL + "/oauth/token")
 	if err != nil {
 		l.Error("unable to retrieve strava tokens: %v", err)
 		return result, err
 	}
-	if resp.IsError() {
-		e := fmt.Errorf("unable to get Strava auth tokens due to bad status code (%v): %v", resp.StatusCode(), reason)
-		l.Error(e.Error())
-		return result, e
-	}
 	return result, nil
 }
 
@@ -160,20 +223,19 @@ type Auth struct {
 
 // @todo figure out how to not rely on rc
 func (sc *Client) refreshAccessToken(cc CallContextalizer) error {
-	url := fmt.Sprintf("%v/oauth/token?client_id=%v&client_secret=%v&grant_type=refresh_token&refresh_token=%v", stravaAPIBaseURL, sc.clientID, sc.clientSecret, sc.refreshToken)
 	var result Auth
-	var reason interface{}
-	r := request.DefaultR(sc.httpClient).SetResult(&result).SetReason(&reason)
-	resp, err := r.Post(url)
+	r := request.DefaultR(sc.httpClient).SetContext(contextOf(cc)).Use(sc.interceptors...).SetResult(&result).
+		SetFormBody(url.Values{
+			"client_id":     {sc.clientID},
+			"client_secret": {sc.clientSecret},
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {sc.refreshToken},
+		})
+	_, err := r.Post(stravaAPIBaseURL + "/oauth/token")
 	if err != nil {
 		cc.L().Error("unable to refresh access token: %v", err)
 		return err
 	}
-	if resp.IsError() {
-		e := fmt.Errorf("unable to refresh Strava access token due to bad status code (%v): %v", resp.StatusCode(), reason)
-		cc.L().Error(e.Error())
-		return e
-	}
 	tokens := Tokens{
 		AccessToken:  result.AccessToken,
 		RefreshToken: result.RefreshToken,
@@ -231,7 +293,7 @@ func (sc *Client) ListActivities(cc CallContextalizer) (Activities, error) {
 	var activites Activities
 	var err error
 	for attempts <= maxUnauthorizedRetries {
-		activites, err = sc.listActivities(cc.L())
+		activites, err = sc.listActivities(contextOf(cc), cc.L())
 		if err != nil {
 			if isUnauthorizedErr(err) {
 				sc.refreshAccessToken(cc)
@@ -245,25 +307,17 @@ func (sc *Client) ListActivities(cc CallContextalizer) (Activities, error) {
 	return activites, err
 }
 
-func (sc *Client) listActivities(l log.Logger) (Activities, error) {
-	url := fmt.Sprintf("%v/athlete/activities?access_token=%v", stravaAPIBaseURL, sc.accessToken)
-	var activites Activities
-	var reason interface{}
-	r := request.DefaultR(sc.httpClient).SetResult(&activites).SetReason(&reason)
-	resp, err := r.Get(url)
+func (sc *Client) listActivities(ctx context.Context, l log.Logger) (Activities, error) {
+	r := request.DefaultR(sc.httpClient).SetContext(ctx).Use(sc.interceptors...).Use(sc.bearerAuth())
+	activites, _, err := request.Fetch[Activities, Fault](r, http.MethodGet, stravaAPIBaseURL+"/athlete/activities")
+	if errors.Is(err, request.ErrUnauthorized) {
+		l.Debug("returning unathorized error to trigger refresh loop")
+		return activites, unauthorizedError{}
+	}
 	if err != nil {
 		l.Error("unable to list activities: %v", err)
 		return activites, err
 	}
-	if resp.IsError() {
-		if resp.StatusCode() == http.StatusUnauthorized {
-			l.Debug("returning unathorized error to trigger refresh loop")
-			return activites, unauthorizedError{}
-		}
-		e := fmt.Errorf("unable to list strava activities due to bad status code (%v): %v", resp.StatusCode(), reason)
-		l.Error(e.Error())
-		return activites, e
-	}
 	return activites, nil
 }
 
@@ -329,7 +383,7 @@ func (sc *Client) GetActivity(cc CallContextalizer, activityID int64) (*Activity
 	var activity *Activity
 	var err error
 	for attempts <= maxUnauthorizedRetries {
-		activity, err = sc.getActivity(cc.L(), activityID)
+		activity, err = sc.getActivity(contextOf(cc), cc.L(), activityID)
 		if err != nil {
 			if isUnauthorizedErr(err) {
 				sc.refreshAccessToken(cc)
@@ -343,28 +397,32 @@ func (sc *Client) GetActivity(cc CallContextalizer, activityID int64) (*Activity
 	return activity, err
 }
 
-func (sc *Client) getActivity(l log.Logger, activityID int64) (*Activity, error) {
-	url := fmt.Sprintf("%v/activities/%v?access_token=%v", stravaAPIBaseURL, activityID, sc.accessToken)
-	var activity *Activity
-	var reason interface{}
-	r := request.DefaultR(sc.httpClient).SetResult(&activity).SetReason(&reason)
-	resp, err := r.Get(url)
+func (sc *Client) getActivity(ctx context.Context, l log.Logger, activityID int64) (*Activity, error) {
+	r := request.DefaultR(sc.httpClient).SetContext(ctx).Use(sc.interceptors...).Use(sc.bearerAuth()).
+		SetPathParam("id", strconv.FormatInt(activityID, 10))
+	activity, _, err := request.Fetch[*Activity, Fault](r, http.MethodGet, stravaAPIBaseURL+"/activities/{id}")
+	if errors.Is(err, request.ErrUnauthorized) {
+		l.Debug("returning unathorized error to trigger refresh loop")
+		return activity, unauthorizedError{}
+	}
 	if err != nil {
-		l.Error("unable to get strava activity: %v: %v", err, reason)
+		l.Error("unable to get strava activity: %v", err)
 		return activity, err
 	}
-	if resp.IsError() {
-		if resp.StatusCode() == http.StatusUnauthorized {
-			l.Debug("returning unathorized error to trigger refresh loop")
-			return activity, unauthorizedError{}
-		}
-		e := fmt.Errorf("unable to get strava activity due to bad status code (%v): %v", resp.StatusCode(), reason)
-		l.Error(e.Error())
-		return activity, e
-	}
 	return activity, nil
 }
 
+// Fault is the body strava responds with on errors. Api calls failing with a bad status
+// return a request.ReasonError[Fault] holding it.
+type Fault struct {
+	Message string `json:"message"`
+	Errors  []struct {
+		Resource string `json:"resource"`
+		Field    string `json:"field"`
+		Code     string `json:"code"`
+	} `json:"errors"`
+}
+
 type unauthorizedError struct{}
 
 func (ua unauthorizedError) Error() string {
@@ -375,12 +433,10 @@ func (ua unauthorizedError) Code() int {
 	return http.StatusUnauthorized
 }
 
+func (ua unauthorizedError) Is(target error) bool {
+	return target == request.ErrUnauthorized
+}
+
 func isUnauthorizedErr(err error) bool {
-	if _, ok := err.(*unauthorizedError); ok {
-		return ok
-	}
-	if err != nil {
-		return strings.Contains(err.Error(), "invalid token")
-	}
-	return false
+	return errors.Is(err, request.ErrUnauthorized)
 }
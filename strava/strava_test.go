@@ -1,14 +1,16 @@
 package strava_test
 
 import (
-	"bytes"
-	"io/ioutil"
-	"net/http"
+	"context"
+	"errors"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/serendipity-xyz/common/log"
 	"github.com/serendipity-xyz/common/mocks"
+	"github.com/serendipity-xyz/common/request"
+	"github.com/serendipity-xyz/common/storage"
 	"github.com/serendipity-xyz/common/strava"
 	"github.com/stretchr/testify/require"
 )
@@ -24,44 +26,59 @@ func (mock *MockUserService) SetUserTokens(cc strava.CallContextalizer, userID s
 	return nil
 }
 
+type FailingUserService struct{}
+
+func (mock *FailingUserService) SetUserTokens(cc strava.CallContextalizer, userID string, tokens strava.Tokens) error {
+	return errors.New("mock db down")
+}
+
+type MockCallContext struct {
+	l log.Logger
+}
+
+func (cc *MockCallContext) DatabaseManager() storage.Manager { return &mocks.MockDBManager{} }
+
+func (cc *MockCallContext) L() log.Logger { return cc.l }
+
+// MockContextCallContext is a MockCallContext that carries a context.Context
+type MockContextCallContext struct {
+	MockCallContext
+	ctx context.Context
+}
+
+func (cc *MockContextCallContext) Context() context.Context { return cc.ctx }
+
 func TestAuthorizationURL(t *testing.T) {
 	sc := strava.NewClient("mockUserID", strava.Tokens{}, &MockUserService{}, &strava.ClientParams{
 		ClientID:    "mockClientId",
 		RedirectURI: "mockRedirecturi",
 	})
 	url := sc.AuthorizationURL("mockScope", strava.Web)
-	require.Equal(t, "https://www.strava.com/oauth/authorize?client_id=mockClientId&response_type=code&redirect_uri=mockRedirecturi&approval_prompt=auto&scope=mockScope", url, "urls should match [0]")
+	require.Equal(t, "https://www.strava.com/oauth/authorize?approval_prompt=auto&client_id=mockClientId&redirect_uri=mockRedirecturi&response_type=code&scope=mockScope", url, "urls should match [0]")
+	url = sc.AuthorizationURL("read,activity:read_all", strava.IOS)
+	require.Equal(t, "strava://oauth/mobile/authorize?approval_prompt=auto&client_id=mockClientId&redirect_uri=mockRedirecturi&response_type=code&scope=read%2Cactivity%3Aread_all", url, "urls should match [1]")
+}
+
+// newTestClient returns a client replaying the cassette in testdata/<name>.json. Its
+// tokens expire in 2100 unless expiresAt is set.
+func newTestClient(t *testing.T, name string, expiresAt int, tokenManager strava.TokenManager) (*strava.Client, *mocks.Cassette) {
+	if expiresAt == 0 {
+		expiresAt = 4102444800
+	}
+	sc := strava.NewClient("mockUserId", strava.Tokens{
+		AccessToken:  "accessToken",
+		RefreshToken: "refreshToken",
+		ExpiresAt:    expiresAt,
+	}, tokenManager, &strava.ClientParams{ClientID: "mockClientId", ClientSecret: "mockClientSecret"})
+	cassette := mocks.NewCassette(t, filepath.Join("testdata", name+".json"), nil)
+	sc.SetClient(cassette)
+	return sc, cassette
 }
 
 func TestCanGenerateTokens(t *testing.T) {
 	stravaClient := strava.NewClient("mockUserId", strava.Tokens{}, &MockUserService{}, &strava.ClientParams{})
-	mc := mocks.NewRequestMock(&mocks.NewRequestMockOpts{
-		Responses: []*http.Response{
-			{
-				StatusCode: 200,
-				Body: ioutil.NopCloser(bytes.NewReader([]byte(`{
-					"token_type": "test",
-					"expires_at": 103, 
-					"expires_in": 3,
-					"refresh_token": "mockRefreshToken",
-					"access_token": "accessToken",
-					"athlete": {
-						"id": 23,
-						"username": "mockUser",
-						"resource_state": 2,
-						"firstname": "myFirstname",
-						"lastname": "myLastname",
-						"city": "myCity",
-						"state": "myState",
-						"country": "myCountry",
-						"sex": "male"
-					}
-				}
-			`))),
-			},
-		},
-	})
-	stravaClient.SetClient(mc)
+	cassette := mocks.NewCassette(t, filepath.Join("testdata", "generate_tokens.json"), nil)
+	stravaClient.SetClient(cassette)
 	res, err := stravaClient.GenerateTokens(log.StdOutLogger{}, "mockCode")
 	require.Nil(t, err, "no error")
 	require.Equal(t, strava.TokenResponse{
@@ -82,33 +99,130 @@ func TestCanGenerateTokens(t *testing.T) {
 			Sex:           "male",
 		},
 	}, res, "unexpected result")
-	require.Equal(t, 1, mc.CallCount(), "only one call")
+	require.Equal(t, 1, cassette.CallCount(), "only one call")
+}
+
+type ctxKey struct{}
+
+func TestGenerateTokensContext(t *testing.T) {
+	stravaClient := strava.NewClient("mockUserId", strava.Tokens{}, &MockUserService{}, &strava.ClientParams{})
+	router := mocks.NewRouter(t)
+	token := router.On("POST", "/api/v3/oauth/token").RespondJSON(200, strava.TokenResponse{AccessToken: "accessToken"})
+	stravaClient.SetClient(router)
+	ctx := context.WithValue(context.Background(), ctxKey{}, "incoming")
+	cc := &MockContextCallContext{MockCallContext: MockCallContext{l: mocks.NewRecordingLogger()}, ctx: ctx}
+	res, err := stravaClient.GenerateTokensContext(cc, "mockCode")
+	require.Nil(t, err, "no error")
+	require.Equal(t, "accessToken", res.AccessToken, "tokens")
+	require.Equal(t, "incoming", token.Calls()[0].Context().Value(ctxKey{}), "request made with the call context")
+}
+
+func TestWarnsWhenRefreshedTokensCannotBeSaved(t *testing.T) {
+	stravaClient, cassette := newTestClient(t, "refresh_then_list", 1, &FailingUserService{})
+	l := mocks.NewRecordingLogger()
+	_, err := stravaClient.ListActivities(&MockCallContext{l: l})
+	require.Nil(t, err, "no error")
+	require.Equal(t, 2, cassette.CallCount(), "refresh then list")
+	l.ExpectInfo(t, "detected expired access token")
+	l.ExpectWarn(t, "unable to update users access tokens in db: mock db down")
+	l.ExpectNoErrors(t)
 }
 
 func TestCanListActivities(t *testing.T) {
-	t.Skip("todo")
+	stravaClient, cassette := newTestClient(t, "list_activities", 0, &MockUserService{})
+	activities, err := stravaClient.ListActivities(&MockCallContext{l: mocks.NewRecordingLogger()})
+	require.Nil(t, err, "no error")
+	require.Len(t, activities, 2, "activities")
+	require.Equal(t, int64(123), activities[0].ID, "id")
+	require.Equal(t, "Morning Run", activities[0].Name, "name")
+	require.Equal(t, "Ride", activities[1].SportType, "sport type")
+	require.Equal(t, 1, cassette.CallCount(), "one call")
 }
 
 func TestCanGetActivity(t *testing.T) {
-	t.Skip("todo")
+	stravaClient, _ := newTestClient(t, "get_activity", 0, &MockUserService{})
+	activity, err := stravaClient.GetActivity(&MockCallContext{l: mocks.NewRecordingLogger()}, 123)
+	require.Nil(t, err, "no error")
+	require.Equal(t, int64(123), activity.ID, "id")
+	require.Equal(t, "Morning Run", activity.Name, "name")
+	require.Equal(t, 8046.7, activity.Distance, "distance")
+	require.Equal(t, []float64{40.7, -74.01}, activity.StartLatlng, "start")
+	require.Equal(t, "New York", activity.LocationCity, "city")
 }
 
 func TestCanRetry500s(t *testing.T) {
-	t.Skip("todo")
+	stravaClient, cassette := newTestClient(t, "retry_500s", 0, &MockUserService{})
+	l := mocks.NewRecordingLogger()
+	activity, err := stravaClient.GetActivity(&MockCallContext{l: l}, 123)
+	require.Nil(t, err, "no error")
+	require.Equal(t, "Morning Run", activity.Name, "name")
+	require.Equal(t, 2, cassette.CallCount(), "retried once")
+	l.ExpectNoErrors(t)
 }
 
 func TestCanRetryExpiredTokens(t *testing.T) {
-	t.Skip("todo")
+	stravaClient, cassette := newTestClient(t, "refresh_then_list", 1, &MockUserService{})
+	l := mocks.NewRecordingLogger()
+	_, err := stravaClient.ListActivities(&MockCallContext{l: l})
+	require.Nil(t, err, "no error")
+	require.Equal(t, 2, cassette.CallCount(), "refresh then list")
+	l.ExpectInfo(t, "detected expired access token")
+	l.ExpectNoErrors(t)
 }
 
 func TestCanRetryUnauthorizedErrs(t *testing.T) {
-	t.Skip("todo")
+	stravaClient, cassette := newTestClient(t, "unauthorized_then_refresh", 0, &MockUserService{})
+	l := mocks.NewRecordingLogger()
+	activity, err := stravaClient.GetActivity(&MockCallContext{l: l}, 123)
+	require.Nil(t, err, "no error")
+	require.Equal(t, "Morning Run", activity.Name, "name")
+	require.Equal(t, 3, cassette.CallCount(), "get, refresh, get")
+	l.ExpectDebug(t, "returning unathorized error to trigger refresh loop")
+	l.ExpectNoErrors(t)
 }
 
 func TestMax500Retrys(t *testing.T) {
-	t.Skip("todo")
+	stravaClient, cassette := newTestClient(t, "max_500s", 0, &MockUserService{})
+	l := mocks.NewRecordingLogger()
+	_, err := stravaClient.GetActivity(&MockCallContext{l: l}, 123)
+	require.True(t, errors.Is(err, request.ErrMaxRetriesExhausted), "retries exhausted")
+	var fault request.ReasonError[strava.Fault]
+	require.True(t, errors.As(err, &fault), "strava fault")
+	require.Equal(t, "Internal Server Error", fault.Reason.Message, "fault message")
+	require.Equal(t, 3, cassette.CallCount(), "first attempt and two retries")
+	l.ExpectError(t, "unable to get strava activity")
 }
 
 func TestMaxUnathorizedRetrys(t *testing.T) {
-	t.Skip("todo")
+	stravaClient, cassette := newTestClient(t, "max_unauthorized", 0, &MockUserService{})
+	l := mocks.NewRecordingLogger()
+	_, err := stravaClient.GetActivity(&MockCallContext{l: l}, 123)
+	require.True(t, errors.Is(err, request.ErrUnauthorized), "unauthorized")
+	require.Equal(t, 4, cassette.CallCount(), "refreshed once per unauthorized response")
+	require.Empty(t, cassette.Unused(), "every interaction used")
+}
+
+func TestRefreshedTokenIsUsed(t *testing.T) {
+	sc := strava.NewClient("mockUserId", strava.Tokens{AccessToken: "accessToken", RefreshToken: "refreshToken", ExpiresAt: 4102444800}, &MockUserService{}, &strava.ClientParams{ClientID: "mockClientId"})
+	router := mocks.NewRouter(t)
+	router.On("GET", "/api/v3/activities/{id}").
+		WithHeader("Authorization", "Bearer accessToken").
+		Respond(401, `{"message": "Authorization Error"}`).
+		Times(1)
+	router.On("POST", "/api/v3/oauth/token").
+		WithFormValue("grant_type", "refresh_token").
+		WithFormValue("refresh_token", "refreshToken").
+		RespondJSON(200, map[string]interface{}{"access_token": "newAccessToken", "refresh_token": "newRefreshToken", "expires_at": 4102444800}).
+		Times(1)
+	activityRoute := router.On("GET", "/api/v3/activities/{id}").
+		WithHeader("Authorization", "Bearer newAccessToken").
+		Respond(200, `{"id": 123, "name": "Morning Run"}`).
+		Times(1)
+	sc.SetClient(router)
+
+	activity, err := sc.GetActivity(&MockCallContext{l: mocks.NewRecordingLogger()}, 123)
+	require.Nil(t, err, "no error")
+	require.Equal(t, "Morning Run", activity.Name, "activity")
+	require.Equal(t, "/api/v3/activities/123", activityRoute.Calls()[0].URL.Path, "path param filled in")
+	require.Equal(t, 3, router.CallCount(), "get, refresh, get")
 }